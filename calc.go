@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strings"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+	"github.com/goldeneggg/ipcl/lib/writer"
+)
+
+// runCalc evaluates an address calculation:
+//
+//	ADDR             conversions of ADDR
+//	ADDR + N         address N after ADDR
+//	ADDR - N         address N before ADDR
+//	ADDR - ADDR      distance between addresses
+//	CIDR host N      N-th host of CIDR, negative N counts from the end
+func runCalc(oa *optArgs) error {
+	args := oa.args
+	switch {
+	case len(args) == 1:
+		a, err := parser.ParseAddrForm(args[0])
+		if err != nil {
+			return err
+		}
+		return writeAddrForms(a)
+	case len(args) == 3 && args[1] == "host":
+		cidr, err := parser.Parse(args[0])
+		if err != nil {
+			return fmt.Errorf("CIDR string[0] %s validate error: %s\n", args[0], err)
+		}
+		n, err := calcInt(args[2])
+		if err != nil {
+			return err
+		}
+		h, err := cidr.Host(n)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(writer.Out, h)
+		return err
+	case len(args) == 3 && (args[1] == "+" || args[1] == "-"):
+		a, err := parser.ParseAddrForm(args[0])
+		if err != nil {
+			return err
+		}
+		if args[1] == "-" && !isInt(args[2]) {
+			to, err := parser.ParseAddrForm(args[2])
+			if err != nil {
+				return err
+			}
+			d, err := parser.Distance(to, a)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(writer.Out, d)
+			return err
+		}
+		n, err := calcInt(args[2])
+		if err != nil {
+			return err
+		}
+		if args[1] == "-" {
+			n.Neg(n)
+		}
+		b, err := parser.OffsetAddr(a, n)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(writer.Out, b)
+		return err
+	}
+	return fmt.Errorf("calc expression %s is invalid\n", strings.Join(args, " "))
+}
+
+func writeAddrForms(a netip.Addr) error {
+	_, err := fmt.Fprintf(writer.Out, `address : %s
+integer : %s
+hex     : %s
+binary  : %s
+mapped  : %s
+`, a, parser.AddrToInt(a), parser.AddrHex(a), parser.AddrBinary(a), parser.MappedAddr(a))
+	return err
+}
+
+// isInt reports whether s is a decimal integer rather than an address.
+func isInt(s string) bool {
+	_, ok := new(big.Int).SetString(s, 10)
+	return ok
+}
+
+func calcInt(s string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an integer\n", s)
+	}
+	return n, nil
+}
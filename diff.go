@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/goldeneggg/ipcl/lib/cidrlist"
+	"github.com/goldeneggg/ipcl/lib/parser"
+	"github.com/goldeneggg/ipcl/lib/writer"
+)
+
+// Exit status of diff command like diff(1).
+const (
+	diffChanged = 1
+	diffTrouble = 2
+)
+
+// statusError is an error of a command which exits with status. err is nil
+// if nothing is printed.
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string {
+	if e.err == nil {
+		return ""
+	}
+	return e.err.Error()
+}
+
+// runDiff compares the address space of the CIDR list files old and new.
+// It returns a statusError of diffChanged if any address is added or
+// removed, and of diffTrouble if a line of the files is rejected.
+func runDiff(oa *optArgs) error {
+	if len(oa.args) != 2 {
+		return &statusError{diffTrouble, fmt.Errorf("Old and new CIDR list files are not assigned\n")}
+	}
+
+	var lists [2][]parser.CIDRInfo
+	for i, path := range oa.args {
+		opts := *oa.opts
+		opts.File = path
+		listArgs := &optArgs{opts: &opts}
+		cidrs, err := getCIDRs(listArgs)
+		if err != nil {
+			return &statusError{diffTrouble, err}
+		}
+		if listArgs.rejected > 0 {
+			return &statusError{diffTrouble, fmt.Errorf("%d lines of %s are rejected\n", listArgs.rejected, path)}
+		}
+		lists[i] = cidrs
+	}
+
+	d := cidrlist.DiffOf(lists[0], lists[1])
+	if err := writer.WriteDiff(outputFormat(oa), oa.args[0], oa.args[1], d); err != nil {
+		return &statusError{diffTrouble, err}
+	}
+	if d.Changed() {
+		return &statusError{diffChanged, nil}
+	}
+	return nil
+}
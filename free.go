@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/goldeneggg/ipcl/lib/ipset"
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// runFree writes the free space of the parent networks which is not used by
+// the CIDRs of -f, as minimal CIDRs or as the first free networks of
+// --size.
+func runFree(oa *optArgs) error {
+	parents, used, err := parentsAndUsed(oa)
+	if err != nil {
+		return err
+	}
+
+	free := ipset.New(parents...).Difference(ipset.New(used...))
+	if oa.opts.Size == 0 {
+		return write(free.CIDRs(), oa)
+	}
+
+	if oa.opts.Count < 1 {
+		return fmt.Errorf("count %d must be positive\n", oa.opts.Count)
+	}
+	subnets := free.Subnets(oa.opts.Size, oa.opts.Count)
+	if err := write(subnets, oa); err != nil {
+		return err
+	}
+	if len(subnets) < oa.opts.Count {
+		return fmt.Errorf("only %d free /%d networks are found\n", len(subnets), oa.opts.Size)
+	}
+	return nil
+}
+
+// parentsAndUsed parses the arguments as parent networks and reads the used
+// networks from -f. A rejected line of -f is an error, because the network
+// of the line would be reported as free.
+func parentsAndUsed(oa *optArgs) ([]parser.CIDRInfo, []parser.CIDRInfo, error) {
+	if len(oa.args) == 0 {
+		return nil, nil, fmt.Errorf("Parent CIDR is not assigned\n")
+	}
+	if oa.opts.File == "" {
+		return nil, nil, fmt.Errorf("CIDR list file of used networks is not assigned\n")
+	}
+
+	var parents []parser.CIDRInfo
+	for i, a := range oa.args {
+		p, err := parser.Parse(a)
+		if err != nil {
+			return nil, nil, fmt.Errorf("CIDR string[%d] %s validate error: %s\n", i, a, err)
+		}
+		parents = append(parents, p)
+	}
+
+	usedArgs := &optArgs{opts: oa.opts}
+	used, err := getCIDRs(usedArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if usedArgs.rejected > 0 {
+		return nil, nil, fmt.Errorf("%d lines of used networks are rejected\n", usedArgs.rejected)
+	}
+
+	return parents, used, nil
+}
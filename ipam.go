@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/goldeneggg/ipcl/lib/ipam"
+	"github.com/goldeneggg/ipcl/lib/ipset"
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// ipamCommands are the sub commands of ipam. Each command receives the
+// opened database and the remaining arguments, and reports whether the
+// database is changed. A command which changes the database returns the
+// CIDRs to write instead of writing them, so that nothing is reported before
+// the change is saved.
+var ipamCommands = map[string]func(db *ipam.DB, oa *optArgs) ([]parser.CIDRInfo, bool, error){
+	"pools":       ipamPools,
+	"add-pool":    ipamAddPool,
+	"remove-pool": ipamRemovePool,
+	"list":        ipamList,
+	"alloc":       ipamAlloc,
+	"claim":       ipamClaim,
+	"release":     ipamRelease,
+}
+
+// runIpam runs an ipam sub command on the file of --db. The file is locked
+// while the command runs, and saved if the command changes it before the
+// result is written.
+func runIpam(oa *optArgs) error {
+	if len(oa.args) == 0 {
+		return fmt.Errorf("ipam command is not assigned\n")
+	}
+	cmd, ok := ipamCommands[oa.args[0]]
+	if !ok {
+		return fmt.Errorf("ipam command %s is not supported\n", oa.args[0])
+	}
+
+	db, err := ipam.Open(oa.opts.DB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result, changed, err := cmd(db, &optArgs{opts: oa.opts, args: oa.args[1:]})
+	if err != nil {
+		return err
+	}
+	if changed {
+		if err := db.Save(); err != nil {
+			return err
+		}
+	}
+	if result != nil {
+		return write(result, oa)
+	}
+	return nil
+}
+
+func ipamPools(db *ipam.DB, oa *optArgs) ([]parser.CIDRInfo, bool, error) {
+	return nil, false, write(db.Pools(), oa)
+}
+
+func ipamAddPool(db *ipam.DB, oa *optArgs) ([]parser.CIDRInfo, bool, error) {
+	cidr, label, err := cidrArg(oa)
+	if err != nil {
+		return nil, false, err
+	}
+	pool, err := db.AddPool(cidr, label)
+	if err != nil {
+		return nil, false, err
+	}
+	return []parser.CIDRInfo{pool}, true, nil
+}
+
+func ipamRemovePool(db *ipam.DB, oa *optArgs) ([]parser.CIDRInfo, bool, error) {
+	cidr, _, err := cidrArg(oa)
+	if err != nil {
+		return nil, false, err
+	}
+	return nil, true, db.RemovePool(cidr)
+}
+
+func ipamList(db *ipam.DB, oa *optArgs) ([]parser.CIDRInfo, bool, error) {
+	var pool netip.Prefix
+	if len(oa.args) > 0 {
+		cidr, _, err := cidrArg(oa)
+		if err != nil {
+			return nil, false, err
+		}
+		pool = cidr.Prefix()
+	}
+	return nil, false, write(db.Allocations(pool), oa)
+}
+
+// ipamAlloc allocates the first free network of --size in the pool, or a
+// single address if --size is not assigned.
+func ipamAlloc(db *ipam.DB, oa *optArgs) ([]parser.CIDRInfo, bool, error) {
+	pool, label, err := cidrArg(oa)
+	if err != nil {
+		return nil, false, err
+	}
+	bits := oa.opts.Size
+	if bits == 0 {
+		bits = pool.NetworkAddr().BitLen()
+	}
+	cidr, err := db.Allocate(pool.Prefix(), bits, label)
+	if err != nil {
+		return nil, false, err
+	}
+	return []parser.CIDRInfo{cidr}, true, nil
+}
+
+func ipamClaim(db *ipam.DB, oa *optArgs) ([]parser.CIDRInfo, bool, error) {
+	cidr, label, err := cidrArg(oa)
+	if err != nil {
+		return nil, false, err
+	}
+	cidr, err = db.Claim(cidr, label)
+	if err != nil {
+		return nil, false, err
+	}
+	return []parser.CIDRInfo{cidr}, true, nil
+}
+
+func ipamRelease(db *ipam.DB, oa *optArgs) ([]parser.CIDRInfo, bool, error) {
+	cidr, _, err := cidrArg(oa)
+	if err != nil {
+		return nil, false, err
+	}
+	cidr, err = db.Release(cidr)
+	if err != nil {
+		return nil, false, err
+	}
+	return []parser.CIDRInfo{cidr}, true, nil
+}
+
+// cidrArg parses the first argument as a CIDR or an address, and joins the
+// remaining arguments as the label.
+func cidrArg(oa *optArgs) (parser.CIDRInfo, string, error) {
+	if len(oa.args) == 0 {
+		return parser.CIDRInfo{}, "", fmt.Errorf("Target CIDR is not assigned\n")
+	}
+	cidr, err := ipset.ParseOperand(oa.args[0])
+	if err != nil {
+		return cidr, "", fmt.Errorf("CIDR string[0] %s validate error: %s\n", oa.args[0], err)
+	}
+	return cidr, strings.Join(oa.args[1:], " "), nil
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"runtime"
+	"strings"
 
+	"github.com/goldeneggg/ipcl/lib/cidrlist"
 	"github.com/goldeneggg/ipcl/lib/parser"
+	"github.com/goldeneggg/ipcl/lib/reader"
 	"github.com/goldeneggg/ipcl/lib/writer"
 	"github.com/jessevdk/go-flags"
 )
@@ -17,16 +20,65 @@ const (
 
 // element names need to Uppercase
 type options struct {
-	Help    bool   `short:"h" long:"help" description:"Show help message"` // not "help" but "Help", because cause error using "-h" option
-	File    string `short:"f" long:"file" description:"Filepath listed target CIDR"`
-	IsCsv   bool   `short:"c" long:"csv" description:"Output format is csv"`
-	IsTsv   bool   `short:"t" long:"tsv" description:"Output format is tsv"`
-	Version bool   `short:"v" long:"version" description:"Print version"`
+	Help        bool     `short:"h" long:"help" description:"Show help message"` // not "help" but "Help", because cause error using "-h" option
+	File        string   `short:"f" long:"file" description:"Filepath listed target CIDR"`
+	Input       string   `short:"i" long:"input" description:"Input format of file" choice:"text" choice:"csv" choice:"tsv" choice:"json" choice:"grep" choice:"ip-route" choice:"ip-addr" choice:"proc-route" choice:"proc-ipv6-route" choice:"aws" choice:"gcp" choice:"azure" choice:"cloudflare" default:"text"`
+	Column      string   `long:"column" description:"Column name of CIDR in csv or tsv file"`
+	Field       string   `long:"field" description:"Field name of CIDR in json file"`
+	Grep        bool     `short:"g" long:"grep" description:"Extract CIDRs and IPs from free text"`
+	Region      []string `long:"region" description:"Region of cloud IP ranges"`
+	Service     []string `long:"service" description:"Service of cloud IP ranges"`
+	IsCsv       bool     `short:"c" long:"csv" description:"Output format is csv"`
+	IsTsv       bool     `short:"t" long:"tsv" description:"Output format is tsv"`
+	IsJson      bool     `short:"j" long:"json" description:"Output format is json"`
+	Output      string   `short:"o" long:"output" description:"Output format" choice:"default" choice:"csv" choice:"tsv" choice:"json" choice:"bind" choice:"iptables" choice:"nftables" choice:"pf" choice:"cisco" choice:"terraform" choice:"aws-json" choice:"aws-yaml" choice:"k8s" choice:"nginx" choice:"apache" choice:"haproxy" choice:"envoy" choice:"dhcpd" choice:"kea" choice:"unified"`
+	NS          string   `long:"ns" description:"Name server of bind zones"`
+	Hostmaster  string   `long:"hostmaster" description:"SOA mailbox of bind zones"`
+	Domain      string   `long:"domain" description:"Domain of PTR names in bind zones"`
+	Action      string   `long:"action" description:"Action of firewall rules" choice:"accept" choice:"drop"`
+	Direction   string   `long:"direction" description:"Direction of firewall rules" choice:"in" choice:"out"`
+	Port        int      `long:"port" description:"TCP port of firewall rules"`
+	Name        string   `long:"name" description:"Name of generated tables, sets and lists"`
+	Policy      string   `long:"policy" description:"Default policy of allow-lists" choice:"allow" choice:"deny"`
+	Router      string   `long:"router" description:"Router of DHCP scopes"`
+	ReserveLow  int      `long:"reserve-low" description:"Addresses reserved at the start of DHCP pools"`
+	ReserveHigh int      `long:"reserve-high" description:"Addresses reserved at the end of DHCP pools"`
+	Size        int      `long:"size" description:"Prefix length of free networks"`
+	Count       int      `short:"n" long:"count" description:"Number of free networks" default:"1"`
+	DB          string   `long:"db" description:"IPAM file" default:"ipam.json"`
+	Color       string   `long:"color" description:"Color of map" choice:"auto" choice:"always" choice:"never" default:"auto"`
+	Tree        bool     `long:"tree" description:"Write nested CIDRs as a tree"`
+	Next        bool     `long:"next" description:"Next network of the same prefix length"`
+	Prev        bool     `long:"prev" description:"Previous network of the same prefix length"`
+	Parent      bool     `long:"parent" description:"Parent network of prefix length one less"`
+	Supernet    *int     `long:"supernet" description:"Supernet of the prefix length"`
+	Sibling     bool     `long:"sibling" description:"Sibling network which merges into the parent"`
+	Children    bool     `long:"children" description:"Two halves of the network"`
+	Summary     bool     `long:"summary" description:"Write summary statistics after output"`
+	ReverseZone bool     `long:"reverse-zone" description:"Add reverse DNS zones to default, csv and tsv output"`
+	Sort        string   `short:"s" long:"sort" description:"Sort key of output" choice:"network" choice:"prefix" choice:"hosts" choice:"family"`
+	Uniq        bool     `short:"u" long:"uniq" description:"Drop duplicate networks"`
+	GroupBy     string   `long:"group-by" description:"Group output with subtotals" choice:"family" choice:"class"`
+	Version     bool     `short:"v" long:"version" description:"Print version"`
 }
 
 type optArgs struct {
-	opts *options
-	args []string
+	opts     *options
+	args     []string
+	rejected int // number of invalid CIDRs found by getCIDRs
+}
+
+// commands are sub commands selected by the first argument.
+// Each command receives the remaining arguments.
+var commands = map[string]func(oa *optArgs) error{
+	"set":    runSet,
+	"lookup": runLookup,
+	"free":   runFree,
+	"ipam":   runIpam,
+	"usage":  runUsage,
+	"map":    runMap,
+	"calc":   runCalc,
+	"diff":   runDiff,
 }
 
 func main() {
@@ -36,14 +88,25 @@ func main() {
 
 	// parse option args
 	opts := &options{}
-	parser := flags.NewParser(opts, flags.PrintErrors)
-	args, err := parser.Parse()
+	parser := flags.NewParser(opts, flags.PrintErrors|flags.PassDoubleDash)
+	argv := os.Args[1:]
+	var calcArgs []string
+	// calc has no options, so its negative numbers such as "host -1" are
+	// arguments. "--" before the expression is still accepted.
+	if len(argv) > 0 && argv[0] == "calc" {
+		argv, calcArgs = argv[:1], argv[1:]
+		if len(calcArgs) > 0 && calcArgs[0] == "--" {
+			calcArgs = calcArgs[1:]
+		}
+	}
+	args, err := parser.ParseArgs(argv)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		printHelp()
 		status = 1
 		return
 	}
+	args = append(args, calcArgs...)
 
 	// print help
 	if opts.Help {
@@ -57,8 +120,25 @@ func main() {
 		return
 	}
 
+	// run sub command
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			if e := cmd(&optArgs{opts: opts, args: args[1:]}); e != nil {
+				status = 1
+				if se, ok := e.(*statusError); ok {
+					status = se.status
+					if se.err == nil {
+						return
+					}
+				}
+				fmt.Fprintf(os.Stderr, "%s\n", e)
+			}
+			return
+		}
+	}
+
 	// get source CIDRs
-	oa := &optArgs{opts, args}
+	oa := &optArgs{opts: opts, args: args}
 	cidrs, e := getCIDRs(oa)
 	if e != nil {
 		fmt.Printf("%s\n", e)
@@ -67,23 +147,40 @@ func main() {
 		return
 	}
 
+	// navigate to neighbor networks
+	if cidrs, e = navigate(cidrs, opts); e != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", e)
+		status = 1
+		return
+	}
+
 	// write
-	write(cidrs, oa)
+	if e := write(cidrs, oa); e != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", e)
+		status = 1
+		return
+	}
 }
 
 func getCIDRs(oa *optArgs) ([]parser.CIDRInfo, error) {
-	var cidrStrs []string
+	var srcs []reader.Source
 	var cidrs []parser.CIDRInfo
 	ac := len(oa.args)
 
 	switch {
-	case ac == 1:
-		cidrStrs = append(cidrStrs, oa.args[0])
-	case ac > 1:
-		cidrStrs = oa.args
+	case ac >= 1 && oa.opts.Grep:
+		var e error
+		srcs, e = reader.Read(strings.NewReader(strings.Join(oa.args, "\n")), reader.FORMAT_GREP, "")
+		if e != nil {
+			return cidrs, e
+		}
+	case ac >= 1:
+		for i, a := range oa.args {
+			srcs = append(srcs, reader.TextSource(i+1, a))
+		}
 	case oa.opts.File != "":
 		var e error
-		cidrStrs, e = fromFile(oa)
+		srcs, e = fromFile(oa)
 		if e != nil {
 			return cidrs, e
 		}
@@ -91,10 +188,16 @@ func getCIDRs(oa *optArgs) ([]parser.CIDRInfo, error) {
 		return cidrs, fmt.Errorf("Target CIDR(or CIDR list file) is not assigned\n")
 	}
 
-	for i, cs := range cidrStrs {
-		c, e := parser.Parse(cs)
+	for i, src := range srcs {
+		c, e := src.Parse()
 		if e != nil {
-			fmt.Fprintf(os.Stderr, "CIDR string[%d] %s validate error: %s\n", i, cs, e)
+			if ac >= 1 && !oa.opts.Grep {
+				fmt.Fprintf(os.Stderr, "CIDR string[%d] %s validate error: %s\n", i, src.CIDR, e)
+			} else {
+				// line number of text and csv, record number of json
+				fmt.Fprintf(os.Stderr, "line %d: CIDR string %s validate error: %s\n", src.Line, src.CIDR, e)
+			}
+			oa.rejected++
 		} else {
 			cidrs = append(cidrs, c)
 		}
@@ -103,41 +206,197 @@ func getCIDRs(oa *optArgs) ([]parser.CIDRInfo, error) {
 	return cidrs, nil
 }
 
-func fromFile(oa *optArgs) ([]string, error) {
-	cidrs := make([]string, 0, 10)
+// fromFile reads sources from the file, or from stdin if the file is "-".
+func fromFile(oa *optArgs) ([]reader.Source, error) {
+	var r io.Reader = os.Stdin
+	if oa.opts.File != "-" {
+		f, err := os.Open(oa.opts.File)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if oa.opts.Grep {
+		return reader.Read(r, reader.FORMAT_GREP, "")
+	}
+
+	column := oa.opts.Column
+	if oa.opts.Input == reader.FORMAT_JSON && oa.opts.Field != "" {
+		column = oa.opts.Field
+	}
 
-	f, err := os.Open(oa.opts.File)
+	srcs, err := reader.Read(r, oa.opts.Input, column)
 	if err != nil {
-		return cidrs, err
+		return srcs, err
 	}
-	defer f.Close()
+	srcs = reader.Filter(srcs, "region", splitValues(oa.opts.Region))
+	srcs = reader.Filter(srcs, "service", splitValues(oa.opts.Service))
+	return srcs, nil
+}
 
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		cidrs = append(cidrs, scanner.Text())
+// splitValues splits comma separated values of repeated options.
+func splitValues(opts []string) []string {
+	var values []string
+	for _, o := range opts {
+		values = append(values, strings.Split(o, ",")...)
 	}
-	if serr := scanner.Err(); serr != nil {
-		fmt.Fprintf(os.Stderr, "%s\n", err)
-		return cidrs, err
+	return values
+}
+
+func write(cidrs []parser.CIDRInfo, oa *optArgs) error {
+	// summary counts all inputs before dropping duplicates
+	var summary cidrlist.Summary
+	if oa.opts.Summary {
+		summary = cidrlist.Summarize(cidrs, oa.rejected)
 	}
 
-	return cidrs, nil
+	if oa.opts.Uniq {
+		cidrs = cidrlist.Uniq(cidrs)
+	}
+	if oa.opts.Sort != "" {
+		if e := cidrlist.Sort(cidrs, oa.opts.Sort); e != nil {
+			return e
+		}
+	}
+
+	if oa.opts.Tree {
+		return writer.WriteTree(outputFormat(oa), cidrlist.Tree(cidrs))
+	}
+
+	w, e := writer.NewFormatWriter(outputFormat(oa), writerOptions(oa))
+	if e != nil {
+		return e
+	}
+	if oa.opts.Summary {
+		sw, ok := w.(writer.SummaryWriter)
+		if !ok {
+			return fmt.Errorf("output format %s does not support --summary\n", outputFormat(oa))
+		}
+		sw.SetSummary(summary)
+	}
+
+	if oa.opts.GroupBy != "" {
+		groups, e := cidrlist.GroupBy(cidrs, oa.opts.GroupBy)
+		if e != nil {
+			return e
+		}
+		return writer.WriteGroups(w, groups)
+	}
+
+	return writer.WriteAll(w, cidrs)
 }
 
-func write(cidrs []parser.CIDRInfo, oa *optArgs) {
-	w := writer.NewWriter(oa.opts.IsCsv, oa.opts.IsTsv)
-	w.Write(cidrs)
+func outputFormat(oa *optArgs) string {
+	switch {
+	case oa.opts.Output != "":
+		return oa.opts.Output
+	case oa.opts.IsCsv:
+		return writer.FORMAT_CSV
+	case oa.opts.IsTsv:
+		return writer.FORMAT_TSV
+	case oa.opts.IsJson:
+		return writer.FORMAT_JSON
+	}
+	return writer.FORMAT_DEFAULT
+}
+
+func writerOptions(oa *optArgs) writer.Options {
+	return writer.Options{
+		NameServer:  oa.opts.NS,
+		Hostmaster:  oa.opts.Hostmaster,
+		Domain:      oa.opts.Domain,
+		Action:      oa.opts.Action,
+		Direction:   oa.opts.Direction,
+		Port:        oa.opts.Port,
+		Name:        oa.opts.Name,
+		Policy:      oa.opts.Policy,
+		Router:      oa.opts.Router,
+		ReserveLow:  oa.opts.ReserveLow,
+		ReserveHigh: oa.opts.ReserveHigh,
+		ReverseZone: oa.opts.ReverseZone,
+	}
 }
 
 func printHelp() {
 	h := `
 Usage:
   ipcl [OPTIONS] <CIDR TEXT | -f <FILE>>
+  ipcl [OPTIONS] <COMMAND> [ARGS]
+
+Commands:
+  set <EXPR>     Calculate a set expression of CIDRs
+                 (ex. '10.0.0.0/8 - 10.1.0.0/16 + 192.168.0.0/16'),
+                 or aggregate the CIDRs of -f <FILE> without EXPR
+  lookup [IP...] Annotate IPs (or stdin) with the longest matching
+                 prefix and label of -f <FILE>
+  free <CIDR...> List the free space of CIDRs which is not used by
+                 the CIDRs of -f <FILE>, or the first -n free
+                 networks of --size
+  ipam <CMD>     Manage pools and allocations in the --db file
+                 pools, add-pool <CIDR> [LABEL], remove-pool <CIDR>,
+                 list [POOL], alloc <POOL> [LABEL] (a --size network
+                 or an address), claim <CIDR|IP> [LABEL],
+                 release <CIDR|IP>
+  usage <CIDR...> Report the utilization of CIDRs by the CIDRs of
+                 -f <FILE> (default, csv, tsv or json)
+  map <CIDR...>  Draw a grid of CIDRs whose cells of --size show the
+                 allocations of -f <FILE>
+  calc <EXPR>    Calculate addresses: ADDR + N, ADDR - N, ADDR - ADDR,
+                 CIDR host N (negative N from the end),
+                 or the forms of ADDR (integer, hex, binary, mapped)
+  diff <OLD> <NEW> Compare the address space of CIDR list files
+                 (default, json or -o unified), exit status is 1 if
+                 changed and 2 on errors
 
 Application Options:
-  -f, --file=    Filepath listed target CIDR
+  -f, --file=    Filepath listed target CIDR ("-" is stdin)
+  -i, --input=   Input format of file (text, csv, tsv, json, grep,
+                 ip-route, ip-addr, proc-route, proc-ipv6-route, aws,
+                 gcp, azure or cloudflare)
+      --region=  Region of cloud IP ranges (repeatable or comma separated)
+      --service= Service of cloud IP ranges (repeatable or comma separated)
+      --column=  Column name of CIDR in csv or tsv file
+                 (default: source_cidr, cidr, prefix or the first column)
+      --field=   Field name of CIDR in json file
+                 (default: source_cidr, cidr or prefix)
+  -g, --grep     Extract CIDRs and IPs from free text of file or
+                 arguments (same as -i grep)
   -c, --csv=     Output format is csv
   -t, --tsv=     Output format is tsv
+  -j, --json     Output format is json
+  -o, --output=  Output format (default, csv, tsv, json, bind,
+                 iptables, nftables, pf, cisco, terraform, aws-json,
+                 aws-yaml, k8s, nginx, apache, haproxy, envoy, dhcpd,
+                 kea or unified)
+      --ns=         Name server of bind zones (ns1.example.com.)
+      --hostmaster= SOA mailbox of bind zones (hostmaster.example.com.)
+      --domain=     Domain of PTR names in bind zones (example.com.)
+      --action=     Action of firewall rules (accept or drop, default accept)
+      --direction=  Direction of firewall rules (in or out, default in)
+      --port=       TCP port of firewall rules
+      --name=       Name of generated tables, sets and lists (ipcl)
+      --policy=     Default policy of allow-lists (allow or deny, default deny)
+      --router=     Router of DHCP scopes (first usable address)
+      --reserve-low=  Addresses reserved at the start of DHCP pools
+      --reserve-high= Addresses reserved at the end of DHCP pools
+      --size=    Prefix length of free networks or map cells
+  -n, --count=   Number of free networks of --size (default 1)
+      --color=   Color of map (auto, always or never, default auto)
+      --db=      IPAM file of ipam command (default ipam.json)
+      --summary  Write summary statistics after output (default and json)
+      --reverse-zone Add reverse DNS zones to default, csv and tsv output
+  -s, --sort=    Sort key of output (network, prefix, hosts or family)
+  -u, --uniq     Drop duplicate networks
+      --group-by= Group output with subtotals (family or class)
+      --tree     Write nested CIDRs as a tree (default or json)
+      --next     Next network of the same prefix length
+      --prev     Previous network of the same prefix length
+      --parent   Parent network of prefix length one less
+      --supernet= Supernet of the prefix length
+      --sibling  Sibling network which merges into the parent
+      --children Two halves of the network
   -v, --version  Print version
 
 Help Options:
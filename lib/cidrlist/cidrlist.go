@@ -0,0 +1,135 @@
+/*
+Package cidrlist provides operations on lists of parsed CIDRs such as
+sorting, deduplication and grouping.
+*/
+package cidrlist
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// Sort keys.
+const (
+	SORT_NETWORK = "network"
+	SORT_PREFIX  = "prefix"
+	SORT_HOSTS   = "hosts"
+	SORT_FAMILY  = "family"
+)
+
+// Group keys.
+const (
+	GROUP_FAMILY = "family"
+	GROUP_CLASS  = "class"
+)
+
+// Group is a named part of a CIDR list.
+type Group struct {
+	Name  string
+	CIDRs []parser.CIDRInfo
+}
+
+// HostCount returns the total number of hosts of the group.
+func (g Group) HostCount() *big.Int {
+	n := new(big.Int)
+	for _, cidr := range g.CIDRs {
+		n.Add(n, cidr.HostCount())
+	}
+	return n
+}
+
+// Sort sorts cidrs in ascending order of key. Ties are broken by the
+// network order of CIDRInfo.Compare, which orders addresses numerically,
+// except family, which keeps the input order in a family.
+//
+//	network  network address, then prefix length
+//	prefix   prefix length
+//	hosts    number of hosts
+//	family   IPv4 first
+func Sort(cidrs []parser.CIDRInfo, key string) error {
+	var less func(a, b parser.CIDRInfo) bool
+	switch key {
+	case SORT_NETWORK:
+		// Compare sorts IPv4 first
+		less = func(a, b parser.CIDRInfo) bool { return false }
+	case SORT_FAMILY:
+		sort.SliceStable(cidrs, func(i, j int) bool {
+			return cidrs[i].Family() == parser.TYPE_IPV4 && cidrs[j].Family() != parser.TYPE_IPV4
+		})
+		return nil
+	case SORT_PREFIX:
+		less = func(a, b parser.CIDRInfo) bool { return a.Bits() < b.Bits() }
+	case SORT_HOSTS:
+		less = func(a, b parser.CIDRInfo) bool { return a.HostCount().Cmp(b.HostCount()) < 0 }
+	default:
+		return fmt.Errorf("sort key %s is not supported\n", key)
+	}
+
+	sort.SliceStable(cidrs, func(i, j int) bool {
+		if less(cidrs[i], cidrs[j]) {
+			return true
+		}
+		if less(cidrs[j], cidrs[i]) {
+			return false
+		}
+		return cidrs[i].Less(cidrs[j])
+	})
+
+	return nil
+}
+
+// Uniq returns cidrs without the CIDRs which have the same network as an
+// earlier one. The order is kept.
+func Uniq(cidrs []parser.CIDRInfo) []parser.CIDRInfo {
+	uniq := make([]parser.CIDRInfo, 0, len(cidrs))
+	seen := make(map[string]bool)
+	for _, cidr := range cidrs {
+		if k := cidr.String(); !seen[k] {
+			seen[k] = true
+			uniq = append(uniq, cidr)
+		}
+	}
+
+	return uniq
+}
+
+// GroupBy splits cidrs into groups by key keeping the order in each group.
+// family groups are "ipv4" and "ipv6". class groups are "class A" to
+// "class E" and "ipv6".
+func GroupBy(cidrs []parser.CIDRInfo, key string) ([]Group, error) {
+	var name func(cidr parser.CIDRInfo) string
+	var order []string
+	switch key {
+	case GROUP_FAMILY:
+		name = func(cidr parser.CIDRInfo) string { return cidr.Family() }
+		order = []string{parser.TYPE_IPV4, parser.TYPE_IPV6}
+	case GROUP_CLASS:
+		name = func(cidr parser.CIDRInfo) string {
+			if c := cidr.Class(); c != "" {
+				return "class " + c
+			}
+			return cidr.Family()
+		}
+		order = []string{"class A", "class B", "class C", "class D", "class E", parser.TYPE_IPV6}
+	default:
+		return nil, fmt.Errorf("group key %s is not supported\n", key)
+	}
+
+	members := make(map[string][]parser.CIDRInfo)
+	for _, cidr := range cidrs {
+		n := name(cidr)
+		members[n] = append(members[n], cidr)
+	}
+
+	var groups []Group
+	for _, n := range order {
+		if len(members[n]) > 0 {
+			groups = append(groups, Group{n, members[n]})
+		}
+	}
+
+	return groups, nil
+}
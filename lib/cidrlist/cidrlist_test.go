@@ -0,0 +1,224 @@
+package cidrlist
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+var listSrc = []string{
+	"192.168.1.0/24",
+	"10.0.0.0/8",
+	"2001:db8::/32",
+	"9.0.0.0/16",
+	"10.0.0.5/8",
+	"172.16.0.0/12",
+	"10.0.0.0/16",
+}
+
+func parseList(t *testing.T, srcs []string) []parser.CIDRInfo {
+	var cidrs []parser.CIDRInfo
+	for _, s := range srcs {
+		c, e := parser.Parse(s)
+		if e != nil {
+			t.Fatalf("Parse(%s) error: %#v", s, e)
+		}
+		cidrs = append(cidrs, c)
+	}
+	return cidrs
+}
+
+func srcStrings(cidrs []parser.CIDRInfo) []string {
+	var strs []string
+	for _, c := range cidrs {
+		strs = append(strs, c.SrcCIDR)
+	}
+	return strs
+}
+
+var sortTests = []struct {
+	key      string
+	expected []string
+}{
+	{SORT_NETWORK, []string{"9.0.0.0/16", "10.0.0.0/8", "10.0.0.5/8", "10.0.0.0/16", "172.16.0.0/12", "192.168.1.0/24", "2001:db8::/32"}},
+	{SORT_PREFIX, []string{"10.0.0.0/8", "10.0.0.5/8", "172.16.0.0/12", "9.0.0.0/16", "10.0.0.0/16", "192.168.1.0/24", "2001:db8::/32"}},
+	{SORT_HOSTS, []string{"192.168.1.0/24", "9.0.0.0/16", "10.0.0.0/16", "172.16.0.0/12", "10.0.0.0/8", "10.0.0.5/8", "2001:db8::/32"}},
+	{SORT_FAMILY, []string{"192.168.1.0/24", "10.0.0.0/8", "9.0.0.0/16", "10.0.0.5/8", "172.16.0.0/12", "10.0.0.0/16", "2001:db8::/32"}},
+}
+
+func TestSort(t *testing.T) {
+	for _, st := range sortTests {
+		cidrs := parseList(t, listSrc)
+		if e := Sort(cidrs, st.key); e != nil {
+			t.Errorf("Sort(%s) error: %#v", st.key, e)
+		}
+		if actual := srcStrings(cidrs); !reflect.DeepEqual(actual, st.expected) {
+			t.Errorf("Sort(%s) actual: %v, expected: %v", st.key, actual, st.expected)
+		}
+	}
+
+	if e := Sort(nil, "label"); e == nil {
+		t.Errorf("Sort(label) expected error")
+	}
+}
+
+func TestUniq(t *testing.T) {
+	actual := srcStrings(Uniq(parseList(t, listSrc)))
+	expected := []string{"192.168.1.0/24", "10.0.0.0/8", "2001:db8::/32", "9.0.0.0/16", "172.16.0.0/12", "10.0.0.0/16"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Uniq actual: %v, expected: %v", actual, expected)
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	groups, e := GroupBy(parseList(t, listSrc), GROUP_CLASS)
+	if e != nil {
+		t.Fatalf("GroupBy error: %#v", e)
+	}
+
+	var actual []string
+	for _, g := range groups {
+		actual = append(actual, g.Name+" "+g.HostCount().String())
+	}
+	expected := []string{"class A 33685496", "class B 1048574", "class C 254", "ipv6 79228162514264337593543950336"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GroupBy(class) actual: %v, expected: %v", actual, expected)
+	}
+
+	groups, _ = GroupBy(parseList(t, listSrc), GROUP_FAMILY)
+	if len(groups) != 2 || len(groups[0].CIDRs) != 6 || groups[0].CIDRs[0].SrcCIDR != "192.168.1.0/24" {
+		t.Errorf("GroupBy(family) actual: %+v", groups)
+	}
+
+	if _, e := GroupBy(nil, "label"); e == nil {
+		t.Errorf("GroupBy(label) expected error")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	s := Summarize(parseList(t, append(listSrc, "2001:db8:1::/48")), 2)
+
+	if s.Inputs != 10 || s.Rejected != 2 || s.Count != 8 {
+		t.Errorf("Summarize counts actual: %d %d %d", s.Inputs, s.Rejected, s.Count)
+	}
+	// 10.0.0.0/8 covers 10.0.0.0/16, 2001:db8::/32 covers 2001:db8:1::/48
+	expected := "79228162514264337593561841920"
+	if s.Covered.String() != expected {
+		t.Errorf("Summarize Covered actual: %s, expected: %s", s.Covered, expected)
+	}
+	if s.Largest.String() != "2001:db8::/32" || s.Smallest.String() != "192.168.1.0/24" {
+		t.Errorf("Summarize Largest, Smallest actual: %s %s", s.Largest, s.Smallest)
+	}
+
+	if len(s.Families) != 2 {
+		t.Fatalf("Summarize Families actual: %+v", s.Families)
+	}
+	v4 := s.Families[0]
+	if v4.Family != parser.TYPE_IPV4 || v4.Count != 6 || v4.Covered.String() != "17891584" || v4.Largest.SrcCIDR != "10.0.0.0/8" {
+		t.Errorf("Summarize ipv4 actual: %s %d %s %s", v4.Family, v4.Count, v4.Covered, v4.Largest.SrcCIDR)
+	}
+
+	empty := Summarize(nil, 1)
+	if empty.Inputs != 1 || empty.Covered.Sign() != 0 || empty.Largest.IsValid() || len(empty.Families) != 0 {
+		t.Errorf("Summarize empty actual: %+v", empty)
+	}
+}
+
+func TestUsageOf(t *testing.T) {
+	parent := parseList(t, []string{"10.0.0.0/22"})[0]
+	children := parseList(t, []string{"10.0.1.0/24", "10.0.0.0/26", "10.0.0.32/27", "192.168.0.0/24", "10.0.3.0/25"})
+
+	u := UsageOf(parent, children)
+	if u.Addresses.String() != "1024" || u.Used.String() != "448" || u.Free.String() != "576" {
+		t.Errorf("UsageOf addresses actual: %s %s %s", u.Addresses, u.Used, u.Free)
+	}
+	if p := u.Percent(u.Used); p != 43.75 {
+		t.Errorf("Percent actual: %v", p)
+	}
+	if u.FreeBlocks != 4 {
+		t.Errorf("FreeBlocks actual: %d", u.FreeBlocks)
+	}
+	if u.LargestFree.String() != "10.0.2.0-10.0.2.255" || u.LargestCIDR.String() != "10.0.2.0/24" {
+		t.Errorf("LargestFree actual: %s %s", u.LargestFree, u.LargestCIDR)
+	}
+
+	var actual []string
+	for _, c := range u.Children {
+		actual = append(actual, c.CIDR.String()+" "+c.Addresses.String())
+	}
+	expected := []string{"10.0.0.0/26 64", "10.0.0.32/27 32", "10.0.1.0/24 256", "10.0.3.0/25 128"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Children actual: %v, expected: %v", actual, expected)
+	}
+
+	u = UsageOf(parent, parseList(t, []string{"10.0.0.0/16"}))
+	if u.Free.Sign() != 0 || u.FreeBlocks != 0 || u.LargestFree.From.IsValid() || u.Children[0].Addresses.String() != "1024" {
+		t.Errorf("UsageOf full actual: %#v", u)
+	}
+}
+
+func TestTree(t *testing.T) {
+	cidrs := parseList(t, []string{"10.0.1.0/24", "10.0.0.0/16", "2001:db8::/48", "10.0.0.0/20", "192.168.0.0/24", "10.0.0.0/24", "10.0.16.0/20", "10.0.0.0/16"})
+
+	var actual []string
+	var walk func(nodes []*Node, depth int)
+	walk = func(nodes []*Node, depth int) {
+		for _, n := range nodes {
+			actual = append(actual, fmt.Sprintf("%d %s", depth, n.CIDR))
+			walk(n.Children, depth+1)
+		}
+	}
+	roots := Tree(cidrs)
+	walk(roots, 0)
+
+	expected := []string{
+		"0 10.0.0.0/16",
+		"0 10.0.0.0/16",
+		"1 10.0.0.0/20",
+		"2 10.0.0.0/24",
+		"2 10.0.1.0/24",
+		"1 10.0.16.0/20",
+		"0 192.168.0.0/24",
+		"0 2001:db8::/48",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Tree actual: %v, expected: %v", actual, expected)
+	}
+
+	n := roots[1].Children[0]
+	if u := srcStrings(n.Unallocated()); !reflect.DeepEqual(u, []string{"10.0.2.0/23", "10.0.4.0/22", "10.0.8.0/21"}) {
+		t.Errorf("Unallocated actual: %v", u)
+	}
+	if c := n.UnallocatedCount().String(); c != "3584" {
+		t.Errorf("UnallocatedCount actual: %s", c)
+	}
+}
+
+func TestDiffOf(t *testing.T) {
+	old := parseList(t, []string{"10.0.0.0/24", "10.0.1.0/24", "192.168.0.0/24"})
+	new := parseList(t, []string{"10.0.0.0/25", "10.0.0.128/25", "10.0.2.0/24", "192.168.0.0/25"})
+
+	d := DiffOf(old, new)
+	if a := srcStrings(d.Added); !reflect.DeepEqual(a, []string{"10.0.2.0/24"}) {
+		t.Errorf("Added actual: %v", a)
+	}
+	if r := srcStrings(d.Removed); !reflect.DeepEqual(r, []string{"10.0.1.0/24", "192.168.0.128/25"}) {
+		t.Errorf("Removed actual: %v", r)
+	}
+	if u := srcStrings(d.Unchanged); !reflect.DeepEqual(u, []string{"10.0.0.0/24", "192.168.0.0/25"}) {
+		t.Errorf("Unchanged actual: %v", u)
+	}
+	if !d.Changed() {
+		t.Errorf("Changed expected true")
+	}
+	if n := AddrCount(d.Removed); n.Int64() != 384 {
+		t.Errorf("AddrCount actual: %s", n)
+	}
+
+	same := DiffOf(parseList(t, []string{"10.0.0.0/24"}), parseList(t, []string{"10.0.0.128/25", "10.0.0.0/25"}))
+	if same.Changed() || len(same.Unchanged) != 1 {
+		t.Errorf("same coverage actual: %+v", same)
+	}
+}
@@ -0,0 +1,41 @@
+package cidrlist
+
+import (
+	"math/big"
+
+	"github.com/goldeneggg/ipcl/lib/ipset"
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// Diff is the difference of the address space covered by two CIDR lists.
+// Each list is of minimal CIDRs in address order.
+type Diff struct {
+	Added     []parser.CIDRInfo // in the new list only
+	Removed   []parser.CIDRInfo // in the old list only
+	Unchanged []parser.CIDRInfo // in both lists
+}
+
+// DiffOf compares the addresses covered by old and new, so the way the
+// addresses are split into CIDRs does not matter.
+func DiffOf(old []parser.CIDRInfo, new []parser.CIDRInfo) Diff {
+	oldSet, newSet := ipset.New(old...), ipset.New(new...)
+	return Diff{
+		Added:     newSet.Difference(oldSet).CIDRs(),
+		Removed:   oldSet.Difference(newSet).CIDRs(),
+		Unchanged: oldSet.Intersect(newSet).CIDRs(),
+	}
+}
+
+// Changed reports whether any address is added or removed.
+func (d Diff) Changed() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0
+}
+
+// AddrCount returns the number of addresses of cidrs, which do not overlap.
+func AddrCount(cidrs []parser.CIDRInfo) *big.Int {
+	total := new(big.Int)
+	for _, c := range cidrs {
+		total.Add(total, c.AddrCount())
+	}
+	return total
+}
@@ -0,0 +1,70 @@
+package cidrlist
+
+import (
+	"math/big"
+
+	"github.com/goldeneggg/ipcl/lib/ipset"
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// Stats is statistics of a CIDR list.
+type Stats struct {
+	Count    int
+	Covered  *big.Int        // number of addresses, overlaps are counted once
+	Largest  parser.CIDRInfo // CIDR which has the most addresses
+	Smallest parser.CIDRInfo // CIDR which has the fewest addresses
+}
+
+// FamilyStats is Stats of one address family.
+type FamilyStats struct {
+	Family string
+	Stats
+}
+
+// Summary is statistics of a batch run.
+type Summary struct {
+	Inputs   int
+	Rejected int
+	Stats    // of the valid CIDRs
+	Families []FamilyStats
+}
+
+// Summarize returns the Summary of valid cidrs and the number of rejected
+// inputs.
+func Summarize(cidrs []parser.CIDRInfo, rejected int) Summary {
+	s := Summary{
+		Inputs:   len(cidrs) + rejected,
+		Rejected: rejected,
+		Stats:    stats(cidrs),
+	}
+
+	groups, _ := GroupBy(cidrs, GROUP_FAMILY)
+	for _, g := range groups {
+		s.Families = append(s.Families, FamilyStats{g.Name, stats(g.CIDRs)})
+	}
+
+	return s
+}
+
+func stats(cidrs []parser.CIDRInfo) Stats {
+	st := Stats{
+		Count:   len(cidrs),
+		Covered: ipset.New(cidrs...).AddrCount(),
+	}
+
+	for i, cidr := range cidrs {
+		if i == 0 {
+			st.Largest, st.Smallest = cidr, cidr
+			continue
+		}
+		n := cidr.AddrCount()
+		if c := n.Cmp(st.Largest.AddrCount()); c > 0 || (c == 0 && cidr.Less(st.Largest)) {
+			st.Largest = cidr
+		}
+		if c := n.Cmp(st.Smallest.AddrCount()); c < 0 || (c == 0 && cidr.Less(st.Smallest)) {
+			st.Smallest = cidr
+		}
+	}
+
+	return st
+}
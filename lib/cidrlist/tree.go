@@ -0,0 +1,68 @@
+package cidrlist
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/goldeneggg/ipcl/lib/ipset"
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// Node is a CIDR in the containment tree of a CIDR list.
+type Node struct {
+	CIDR     parser.CIDRInfo
+	Children []*Node // in address order
+}
+
+// Tree returns the roots of the containment tree of cidrs. A CIDR is a
+// child of the smallest other CIDR which contains it. Duplicates are
+// siblings.
+func Tree(cidrs []parser.CIDRInfo) []*Node {
+	sorted := make([]parser.CIDRInfo, len(cidrs))
+	copy(sorted, cidrs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Less(sorted[j])
+	})
+
+	var roots []*Node
+	var stack []*Node
+	for _, c := range sorted {
+		n := &Node{CIDR: c}
+		for len(stack) > 0 && !contains(stack[len(stack)-1].CIDR, c) {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, n)
+		} else {
+			top := stack[len(stack)-1]
+			top.Children = append(top.Children, n)
+		}
+		stack = append(stack, n)
+	}
+
+	return roots
+}
+
+// contains reports whether parent strictly contains c.
+func contains(parent parser.CIDRInfo, c parser.CIDRInfo) bool {
+	return parent.Family() == c.Family() && parent.Bits() < c.Bits() && parent.ContainsAddr(c.NetworkAddr())
+}
+
+// Unallocated returns the minimal CIDRs of n which are not in any child.
+func (n *Node) Unallocated() []parser.CIDRInfo {
+	children := make([]parser.CIDRInfo, len(n.Children))
+	for i, c := range n.Children {
+		children[i] = c.CIDR
+	}
+	return ipset.New(n.CIDR).Difference(ipset.New(children...)).CIDRs()
+}
+
+// UnallocatedCount returns the number of addresses of n which are not in
+// any child.
+func (n *Node) UnallocatedCount() *big.Int {
+	total := new(big.Int)
+	for _, c := range n.Unallocated() {
+		total.Add(total, c.AddrCount())
+	}
+	return total
+}
@@ -0,0 +1,69 @@
+package cidrlist
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/goldeneggg/ipcl/lib/ipset"
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// Usage is the utilization of a parent network by its children.
+type Usage struct {
+	Parent      parser.CIDRInfo
+	Addresses   *big.Int // number of addresses of Parent
+	Used        *big.Int // addresses in children, overlaps are counted once
+	Free        *big.Int
+	FreeBlocks  int             // number of minimal free CIDRs
+	LargestFree parser.Range    // largest contiguous free range, zero if full
+	LargestCIDR parser.CIDRInfo // largest free CIDR, zero if full
+	Children    []ChildUsage    // in address order
+}
+
+// ChildUsage is the part of a parent network used by a child.
+type ChildUsage struct {
+	CIDR      parser.CIDRInfo
+	Addresses *big.Int // addresses of the child in the parent
+}
+
+// UsageOf returns the Usage of parent by the children which overlap it.
+func UsageOf(parent parser.CIDRInfo, children []parser.CIDRInfo) Usage {
+	ps := ipset.New(parent)
+	u := Usage{Parent: parent, Addresses: parent.AddrCount()}
+
+	var in []parser.CIDRInfo
+	for _, c := range children {
+		n := ps.Intersect(ipset.New(c)).AddrCount()
+		if n.Sign() == 0 {
+			continue
+		}
+		in = append(in, c)
+		u.Children = append(u.Children, ChildUsage{CIDR: c, Addresses: n})
+	}
+	sort.SliceStable(u.Children, func(i, j int) bool {
+		return u.Children[i].CIDR.Less(u.Children[j].CIDR)
+	})
+
+	free := ps.Difference(ipset.New(in...))
+	u.Free = free.AddrCount()
+	u.Used = new(big.Int).Sub(u.Addresses, u.Free)
+	for _, c := range free.CIDRs() {
+		u.FreeBlocks++
+		if !u.LargestCIDR.IsValid() || c.Bits() < u.LargestCIDR.Bits() {
+			u.LargestCIDR = c
+		}
+	}
+	for _, r := range free.Ranges() {
+		if !u.LargestFree.From.IsValid() || r.AddrCount().Cmp(u.LargestFree.AddrCount()) > 0 {
+			u.LargestFree = r
+		}
+	}
+
+	return u
+}
+
+// Percent returns n as the percentage of the addresses of the parent.
+func (u Usage) Percent(n *big.Int) float64 {
+	f, _ := new(big.Rat).SetFrac(new(big.Int).Mul(n, big.NewInt(100)), u.Addresses).Float64()
+	return f
+}
@@ -0,0 +1,273 @@
+/*
+Package ipam provides a small IP address manager which keeps pools and
+allocations in a local JSON file.
+
+Open locks the file until Close, so concurrent processes which open the same
+file are serialized. Changes are written by Save.
+
+	db, err := ipam.Open("ipam.json")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	cidr, err := db.Allocate(pool, 24, "web")
+	if err != nil {
+		return err
+	}
+	return db.Save()
+*/
+package ipam
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/goldeneggg/ipcl/lib/ipset"
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// Record is a pool or an allocation in the file.
+type Record struct {
+	CIDR  parser.CIDRInfo `json:"cidr"`
+	Label string          `json:"label,omitempty"`
+}
+
+type data struct {
+	Pools       []Record `json:"pools"`
+	Allocations []Record `json:"allocations"`
+}
+
+// DB is an opened IPAM file.
+type DB struct {
+	path string
+	lock *os.File
+	data data
+}
+
+// Open locks the file at path and reads it. A missing file is an empty
+// database which is created by Save. The lock file is path + ".lock".
+func Open(path string) (*DB, error) {
+	lf, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(lf); err != nil {
+		lf.Close()
+		return nil, err
+	}
+
+	db := &DB{path: path, lock: lf}
+	b, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return db, nil
+	case err != nil:
+		db.Close()
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &db.data); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s is not an ipam file: %s\n", path, err)
+	}
+
+	return db, nil
+}
+
+// Close releases the lock without saving.
+func (db *DB) Close() error {
+	if db.lock == nil {
+		return nil
+	}
+	unlockFile(db.lock)
+	err := db.lock.Close()
+	db.lock = nil
+	return err
+}
+
+// Save writes the database to the file. The file is replaced at once, so a
+// reader never sees a partial file. The mode of the file is kept, and a new
+// file is 0644.
+func (db *DB) Save() error {
+	b, err := json.MarshalIndent(db.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(db.path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(db.path), filepath.Base(db.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), db.path)
+}
+
+// Pools returns the pools in address order. Label of each CIDRInfo is the
+// label of the pool.
+func (db *DB) Pools() []parser.CIDRInfo {
+	return cidrs(db.data.Pools)
+}
+
+// Allocations returns the allocations in address order, or only the
+// allocations in pool if pool is valid. Label of each CIDRInfo is the label
+// of the allocation and its "pool" attribute is the pool which contains it.
+func (db *DB) Allocations(pool netip.Prefix) []parser.CIDRInfo {
+	var allocs []parser.CIDRInfo
+	for _, a := range cidrs(db.data.Allocations) {
+		p, _ := db.poolOf(a)
+		if pool.IsValid() && p.Prefix() != pool {
+			continue
+		}
+		a.Attrs = append(a.Attrs, parser.Attr{Key: "pool", Value: p.Prefix().String()})
+		allocs = append(allocs, a)
+	}
+	return allocs
+}
+
+// AddPool adds cidr as a pool. Pools must not overlap.
+func (db *DB) AddPool(cidr parser.CIDRInfo, label string) (parser.CIDRInfo, error) {
+	cidr = parser.FromPrefix(cidr.Prefix())
+	for _, p := range db.data.Pools {
+		if p.CIDR.Prefix().Overlaps(cidr.Prefix()) {
+			return cidr, fmt.Errorf("pool %s overlaps pool %s\n", cidr, p.CIDR)
+		}
+	}
+
+	db.data.Pools = insert(db.data.Pools, Record{CIDR: cidr, Label: label})
+	cidr.Label = label
+	return cidr, nil
+}
+
+// RemovePool removes the pool cidr which has no allocations.
+func (db *DB) RemovePool(cidr parser.CIDRInfo) error {
+	i := find(db.data.Pools, cidr.Prefix())
+	if i < 0 {
+		return fmt.Errorf("pool %s is not found\n", cidr.Prefix())
+	}
+	if allocs := db.Allocations(cidr.Prefix()); len(allocs) > 0 {
+		return fmt.Errorf("pool %s has %d allocations\n", cidr.Prefix(), len(allocs))
+	}
+
+	db.data.Pools = append(db.data.Pools[:i], db.data.Pools[i+1:]...)
+	return nil
+}
+
+// Allocate allocates the first free network of prefix length bits in pool.
+// If bits is the address length, it allocates a single address and skips
+// the network and broadcast addresses of an IPv4 pool.
+func (db *DB) Allocate(pool netip.Prefix, bits int, label string) (parser.CIDRInfo, error) {
+	i := find(db.data.Pools, pool)
+	if i < 0 {
+		return parser.CIDRInfo{}, fmt.Errorf("pool %s is not found\n", pool)
+	}
+	p := db.data.Pools[i].CIDR
+	if bits < p.Bits() || bits > pool.Addr().BitLen() {
+		return parser.CIDRInfo{}, fmt.Errorf("prefix length %d is out of pool %s\n", bits, pool)
+	}
+
+	free := ipset.New(p)
+	if bits == pool.Addr().BitLen() && p.MinAddr().IsValid() {
+		r, _ := parser.NewRange(p.MinAddr(), p.MaxAddr())
+		free = ipset.FromRanges(r)
+	}
+	free = free.Difference(ipset.New(cidrs(db.data.Allocations)...))
+
+	subnets := free.Subnets(bits, 1)
+	if len(subnets) == 0 {
+		return parser.CIDRInfo{}, fmt.Errorf("pool %s has no free /%d networks\n", pool, bits)
+	}
+
+	return db.claim(subnets[0], label), nil
+}
+
+// Claim allocates cidr, which must be in a pool and must not overlap other
+// allocations.
+func (db *DB) Claim(cidr parser.CIDRInfo, label string) (parser.CIDRInfo, error) {
+	cidr = parser.FromPrefix(cidr.Prefix())
+	if _, ok := db.poolOf(cidr); !ok {
+		return cidr, fmt.Errorf("%s is not in any pool\n", cidr)
+	}
+	for _, a := range db.data.Allocations {
+		if a.CIDR.Prefix().Overlaps(cidr.Prefix()) {
+			return cidr, fmt.Errorf("%s overlaps allocation %s\n", cidr, a.CIDR)
+		}
+	}
+
+	return db.claim(cidr, label), nil
+}
+
+func (db *DB) claim(cidr parser.CIDRInfo, label string) parser.CIDRInfo {
+	db.data.Allocations = insert(db.data.Allocations, Record{CIDR: cidr, Label: label})
+	cidr.Label = label
+	return cidr
+}
+
+// Release removes the allocation cidr and returns it.
+func (db *DB) Release(cidr parser.CIDRInfo) (parser.CIDRInfo, error) {
+	i := find(db.data.Allocations, cidr.Prefix())
+	if i < 0 {
+		return cidr, fmt.Errorf("allocation %s is not found\n", cidr.Prefix())
+	}
+
+	r := db.data.Allocations[i]
+	db.data.Allocations = append(db.data.Allocations[:i], db.data.Allocations[i+1:]...)
+	r.CIDR.Label = r.Label
+	return r.CIDR, nil
+}
+
+// poolOf returns the pool which contains cidr.
+func (db *DB) poolOf(cidr parser.CIDRInfo) (parser.CIDRInfo, bool) {
+	for _, p := range db.data.Pools {
+		if p.CIDR.Bits() <= cidr.Bits() && p.CIDR.ContainsAddr(cidr.NetworkAddr()) {
+			return p.CIDR, true
+		}
+	}
+	return parser.CIDRInfo{}, false
+}
+
+func insert(rs []Record, r Record) []Record {
+	rs = append(rs, r)
+	sort.SliceStable(rs, func(i, j int) bool {
+		return rs[i].CIDR.Less(rs[j].CIDR)
+	})
+	return rs
+}
+
+func find(rs []Record, p netip.Prefix) int {
+	for i, r := range rs {
+		if r.CIDR.Prefix() == p.Masked() {
+			return i
+		}
+	}
+	return -1
+}
+
+func cidrs(rs []Record) []parser.CIDRInfo {
+	cs := make([]parser.CIDRInfo, len(rs))
+	for i, r := range rs {
+		cs[i] = r.CIDR
+		cs[i].Label = r.Label
+	}
+	return cs
+}
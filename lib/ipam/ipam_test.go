@@ -0,0 +1,210 @@
+package ipam
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+func mustParse(s string) parser.CIDRInfo {
+	c, err := parser.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func TestAllocate(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "ipam.json"))
+	if err != nil {
+		t.Fatalf("Open error: %#v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.AddPool(mustParse("10.0.0.5/22"), "prod"); err != nil {
+		t.Fatalf("AddPool error: %#v", err)
+	}
+	if _, err := db.AddPool(mustParse("10.0.2.0/24"), ""); err == nil {
+		t.Errorf("AddPool of overlapping pool expected error")
+	}
+	pool := netip.MustParsePrefix("10.0.0.0/22")
+
+	tests := []struct {
+		bits     int
+		expected string
+	}{
+		{24, "10.0.0.0/24"},
+		{26, "10.0.1.0/26"},
+		{24, "10.0.2.0/24"},
+		{32, "10.0.1.64/32"},
+		{24, "10.0.3.0/24"},
+	}
+	for _, tt := range tests {
+		c, err := db.Allocate(pool, tt.bits, "web")
+		if err != nil {
+			t.Errorf("Allocate /%d error: %#v", tt.bits, err)
+			continue
+		}
+		if c.String() != tt.expected || c.Label != "web" {
+			t.Errorf("Allocate /%d actual: %s %s, expected: %s web", tt.bits, c, c.Label, tt.expected)
+		}
+	}
+	if _, err := db.Allocate(pool, 24, ""); err == nil {
+		t.Errorf("Allocate from full pool expected error")
+	}
+	if _, err := db.Allocate(pool, 16, ""); err == nil {
+		t.Errorf("Allocate larger than pool expected error")
+	}
+	if _, err := db.Allocate(netip.MustParsePrefix("192.168.0.0/16"), 24, ""); err == nil {
+		t.Errorf("Allocate from unknown pool expected error")
+	}
+}
+
+func TestAllocateIP(t *testing.T) {
+	db, _ := Open(filepath.Join(t.TempDir(), "ipam.json"))
+	defer db.Close()
+
+	db.AddPool(mustParse("192.168.1.0/30"), "")
+	pool := netip.MustParsePrefix("192.168.1.0/30")
+	for _, expected := range []string{"192.168.1.1/32", "192.168.1.2/32"} {
+		if c, err := db.Allocate(pool, 32, ""); err != nil || c.String() != expected {
+			t.Errorf("Allocate IP actual: %s %v, expected: %s", c, err, expected)
+		}
+	}
+	if c, err := db.Allocate(pool, 32, ""); err == nil {
+		t.Errorf("Allocate IP from full pool expected error, actual: %s", c)
+	}
+}
+
+func TestClaimRelease(t *testing.T) {
+	db, _ := Open(filepath.Join(t.TempDir(), "ipam.json"))
+	defer db.Close()
+
+	db.AddPool(mustParse("10.0.0.0/16"), "")
+	if _, err := db.Claim(mustParse("10.0.1.0/24"), "db"); err != nil {
+		t.Errorf("Claim error: %#v", err)
+	}
+	if _, err := db.Claim(mustParse("10.0.1.128/25"), ""); err == nil {
+		t.Errorf("Claim of overlapping allocation expected error")
+	}
+	if _, err := db.Claim(mustParse("10.1.0.0/24"), ""); err == nil {
+		t.Errorf("Claim out of pools expected error")
+	}
+	if err := db.RemovePool(mustParse("10.0.0.0/16")); err == nil {
+		t.Errorf("RemovePool of used pool expected error")
+	}
+
+	c, err := db.Release(mustParse("10.0.1.0/24"))
+	if err != nil || c.Label != "db" {
+		t.Errorf("Release actual: %s %s %v", c, c.Label, err)
+	}
+	if _, err := db.Release(mustParse("10.0.1.0/24")); err == nil {
+		t.Errorf("Release of released allocation expected error")
+	}
+	if err := db.RemovePool(mustParse("10.0.0.0/16")); err != nil {
+		t.Errorf("RemovePool error: %#v", err)
+	}
+}
+
+func TestSaveOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ipam.json")
+	db, _ := Open(path)
+	db.AddPool(mustParse("10.0.0.0/16"), "prod")
+	db.AddPool(mustParse("2001:db8::/48"), "")
+	db.Claim(mustParse("10.0.5.0/24"), "web")
+	if err := db.Save(); err != nil {
+		t.Fatalf("Save error: %#v", err)
+	}
+	db.Close()
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %#v", err)
+	}
+	defer db.Close()
+
+	pools := db.Pools()
+	if len(pools) != 2 || pools[0].String() != "10.0.0.0/16" || pools[0].Label != "prod" || pools[1].String() != "2001:db8::/48" {
+		t.Errorf("Pools actual: %v", pools)
+	}
+	allocs := db.Allocations(netip.Prefix{})
+	if len(allocs) != 1 || allocs[0].String() != "10.0.5.0/24" || allocs[0].Label != "web" {
+		t.Fatalf("Allocations actual: %v", allocs)
+	}
+	if v, _ := allocs[0].Attr("pool"); v != "10.0.0.0/16" {
+		t.Errorf("pool attribute actual: %s", v)
+	}
+	if allocs := db.Allocations(netip.MustParsePrefix("2001:db8::/48")); len(allocs) != 0 {
+		t.Errorf("Allocations of pool actual: %v", allocs)
+	}
+}
+
+func TestSaveMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file mode is not supported")
+	}
+
+	path := filepath.Join(t.TempDir(), "ipam.json")
+	for _, mode := range []os.FileMode{0644, 0640} {
+		if mode != 0644 {
+			os.Chmod(path, mode)
+		}
+		db, _ := Open(path)
+		db.AddPool(mustParse("10.0.0.0/16"), "prod")
+		if err := db.Save(); err != nil {
+			t.Fatalf("Save error: %#v", err)
+		}
+		db.Close()
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Stat error: %#v", err)
+		}
+		if fi.Mode().Perm() != mode {
+			t.Errorf("mode actual: %v, expected: %v", fi.Mode().Perm(), mode)
+		}
+	}
+}
+
+func TestConcurrentAllocate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ipam.json")
+	db, _ := Open(path)
+	db.AddPool(mustParse("10.0.0.0/24"), "")
+	db.Save()
+	db.Close()
+
+	pool := netip.MustParsePrefix("10.0.0.0/24")
+	const n = 16
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db, err := Open(path)
+			if err != nil {
+				t.Errorf("Open error: %#v", err)
+				return
+			}
+			defer db.Close()
+			if _, err := db.Allocate(pool, 28, ""); err != nil {
+				t.Errorf("Allocate error: %#v", err)
+				return
+			}
+			if err := db.Save(); err != nil {
+				t.Errorf("Save error: %#v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	db, _ = Open(path)
+	defer db.Close()
+	if allocs := db.Allocations(pool); len(allocs) != n {
+		t.Errorf("Allocations actual: %d, expected: %d", len(allocs), n)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package ipam
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile blocks until f is locked exclusively.
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
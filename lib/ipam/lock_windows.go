@@ -0,0 +1,37 @@
+//go:build windows
+
+package ipam
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	lockRetry   = 50 * time.Millisecond
+	lockTimeout = 30 * time.Second
+)
+
+// lockFile blocks until f is locked exclusively. Windows has no flock, so
+// the lock is a file next to f which is created exclusively and removed by
+// unlockFile. A lock left by a killed process has to be removed by hand.
+func lockFile(f *os.File) error {
+	name := f.Name() + ".excl"
+	for start := time.Now(); ; time.Sleep(lockRetry) {
+		lf, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return lf.Close()
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+		if time.Since(start) > lockTimeout {
+			return fmt.Errorf("%s is locked, remove it if no ipcl is running\n", name)
+		}
+	}
+}
+
+func unlockFile(f *os.File) error {
+	return os.Remove(f.Name() + ".excl")
+}
@@ -0,0 +1,139 @@
+package ipset
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// ParseExpr parses a set expression such as
+// "10.0.0.0/8 - 10.1.0.0/16 + 192.168.0.0/16".
+//
+// Operands are CIDRs or bare addresses. Binary operators "+" (union), "-"
+// (difference) and "&" (intersection) are evaluated from left to right.
+// A "!" before an operand takes the complement, and parentheses group
+// sub expressions.
+func ParseExpr(expr string) (IPSet, error) {
+	p := &exprParser{tokens: tokenize(expr)}
+	s, err := p.parseExpr()
+	if err != nil {
+		return IPSet{}, err
+	}
+	if p.pos < len(p.tokens) {
+		return IPSet{}, fmt.Errorf("unexpected %q in set expression\n", p.tokens[p.pos])
+	}
+	return s, nil
+}
+
+// ParseOperand parses a CIDR or a bare address as a CIDR which has only
+// the address.
+func ParseOperand(s string) (parser.CIDRInfo, error) {
+	if !strings.Contains(s, "/") {
+		if addr, err := netip.ParseAddr(s); err == nil {
+			return parser.FromPrefix(netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())), nil
+		}
+	}
+	return parser.Parse(s)
+}
+
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func tokenize(expr string) []string {
+	var tokens []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+
+	for _, r := range expr {
+		switch {
+		case strings.ContainsRune("+-&!()", r):
+			flush()
+			tokens = append(tokens, string(r))
+		case r == ' ' || r == '\t' || r == '\n' || r == ',':
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+func (p *exprParser) next() (string, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, true
+}
+
+func (p *exprParser) parseExpr() (IPSet, error) {
+	s, err := p.parseTerm()
+	if err != nil {
+		return s, err
+	}
+
+	for p.pos < len(p.tokens) {
+		op := p.tokens[p.pos]
+		if op != "+" && op != "-" && op != "&" {
+			break
+		}
+		p.pos++
+
+		o, err := p.parseTerm()
+		if err != nil {
+			return s, err
+		}
+		switch op {
+		case "+":
+			s = s.Union(o)
+		case "-":
+			s = s.Difference(o)
+		case "&":
+			s = s.Intersect(o)
+		}
+	}
+
+	return s, nil
+}
+
+func (p *exprParser) parseTerm() (IPSet, error) {
+	t, ok := p.next()
+	if !ok {
+		return IPSet{}, fmt.Errorf("unexpected end of set expression\n")
+	}
+
+	switch t {
+	case "!":
+		s, err := p.parseTerm()
+		return s.Complement(), err
+	case "(":
+		s, err := p.parseExpr()
+		if err != nil {
+			return s, err
+		}
+		if c, _ := p.next(); c != ")" {
+			return s, fmt.Errorf("missing \")\" in set expression\n")
+		}
+		return s, nil
+	case "+", "-", "&", ")":
+		return IPSet{}, fmt.Errorf("unexpected %q in set expression\n", t)
+	}
+
+	cidr, err := ParseOperand(t)
+	if err != nil {
+		return IPSet{}, err
+	}
+	return New(cidr), nil
+}
@@ -0,0 +1,244 @@
+/*
+Package ipset provides IPSet, a set of IPv4 and IPv6 addresses.
+
+An IPSet is made from parser.CIDRInfo values and can be combined with
+Union, Intersect, Difference and Complement. The result is available as the
+minimal list of CIDRs.
+
+	a, _ := parser.Parse("10.0.0.0/8")
+	b, _ := parser.Parse("10.1.0.0/16")
+	s := ipset.New(a).Difference(ipset.New(b))
+	for _, cidr := range s.CIDRs() {
+		fmt.Println(cidr)
+	}
+*/
+package ipset
+
+import (
+	"math/big"
+	"net/netip"
+	"sort"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// universe is every address of both families.
+var universe = []parser.Range{
+	{From: netip.IPv4Unspecified(), To: netip.AddrFrom4([4]byte{255, 255, 255, 255})},
+	{From: netip.IPv6Unspecified(), To: netip.AddrFrom16([16]byte{
+		255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255})},
+}
+
+// IPSet is a set of addresses. The zero value is an empty set.
+//
+// IPSet is a value type, every operation returns a new set.
+type IPSet struct {
+	// sorted, non overlapping and non adjacent
+	ranges []parser.Range
+}
+
+// New returns the set of addresses in cidrs.
+func New(cidrs ...parser.CIDRInfo) IPSet {
+	ranges := make([]parser.Range, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if cidr.IsValid() {
+			ranges = append(ranges, cidr.Range())
+		}
+	}
+	return FromRanges(ranges...)
+}
+
+// FromRanges returns the set of addresses in ranges.
+func FromRanges(ranges ...parser.Range) IPSet {
+	rs := make([]parser.Range, len(ranges))
+	copy(rs, ranges)
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].From.Less(rs[j].From)
+	})
+
+	return IPSet{merge(rs)}
+}
+
+// All returns the set of every IPv4 and IPv6 address.
+func All() IPSet {
+	return IPSet{universe}
+}
+
+// merge joins overlapping and adjacent ranges of rs sorted by From.
+func merge(rs []parser.Range) []parser.Range {
+	var merged []parser.Range
+	for _, r := range rs {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			if r.From.BitLen() == last.To.BitLen() && (r.From.Compare(last.To) <= 0 || r.From == last.To.Next()) {
+				if last.To.Less(r.To) {
+					last.To = r.To
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+
+	return merged
+}
+
+// IsEmpty reports whether s has no addresses.
+func (s IPSet) IsEmpty() bool {
+	return len(s.ranges) == 0
+}
+
+// Equal reports whether s and o have the same addresses.
+func (s IPSet) Equal(o IPSet) bool {
+	if len(s.ranges) != len(o.ranges) {
+		return false
+	}
+	for i := range s.ranges {
+		if s.ranges[i] != o.ranges[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains reports whether addr is in s.
+func (s IPSet) Contains(addr netip.Addr) bool {
+	i := sort.Search(len(s.ranges), func(i int) bool {
+		return addr.Compare(s.ranges[i].To) <= 0
+	})
+	return i < len(s.ranges) && s.ranges[i].Contains(addr)
+}
+
+// ContainsCIDR reports whether every address of cidr is in s.
+func (s IPSet) ContainsCIDR(cidr parser.CIDRInfo) bool {
+	r := cidr.Range()
+	i := sort.Search(len(s.ranges), func(i int) bool {
+		return r.From.Compare(s.ranges[i].To) <= 0
+	})
+	return i < len(s.ranges) && s.ranges[i].Contains(r.From) && s.ranges[i].Contains(r.To)
+}
+
+// Union returns the addresses in s or o.
+func (s IPSet) Union(o IPSet) IPSet {
+	rs := make([]parser.Range, 0, len(s.ranges)+len(o.ranges))
+	rs = append(rs, s.ranges...)
+	rs = append(rs, o.ranges...)
+	return FromRanges(rs...)
+}
+
+// Intersect returns the addresses in both s and o.
+func (s IPSet) Intersect(o IPSet) IPSet {
+	var rs []parser.Range
+	for i, j := 0, 0; i < len(s.ranges) && j < len(o.ranges); {
+		a, b := s.ranges[i], o.ranges[j]
+		from, to := a.From, a.To
+		if from.Less(b.From) {
+			from = b.From
+		}
+		if b.To.Less(to) {
+			to = b.To
+		}
+		if from.BitLen() == to.BitLen() && from.Compare(to) <= 0 {
+			rs = append(rs, parser.Range{From: from, To: to})
+		}
+
+		if a.To.Less(b.To) {
+			i++
+		} else {
+			j++
+		}
+	}
+
+	return IPSet{rs}
+}
+
+// Difference returns the addresses in s but not in o.
+func (s IPSet) Difference(o IPSet) IPSet {
+	return s.Intersect(o.Complement())
+}
+
+// Complement returns every IPv4 and IPv6 address which is not in s.
+func (s IPSet) Complement() IPSet {
+	var rs []parser.Range
+	for _, u := range universe {
+		cur, done := u.From, false
+		for _, r := range s.ranges {
+			if r.From.BitLen() != u.From.BitLen() {
+				continue
+			}
+			if cur.Less(r.From) {
+				rs = append(rs, parser.Range{From: cur, To: r.From.Prev()})
+			}
+			if r.To == u.To {
+				done = true
+				break
+			}
+			cur = r.To.Next()
+		}
+		if !done {
+			rs = append(rs, parser.Range{From: cur, To: u.To})
+		}
+	}
+
+	return IPSet{rs}
+}
+
+// Ranges returns the ranges of s in address order.
+func (s IPSet) Ranges() []parser.Range {
+	rs := make([]parser.Range, len(s.ranges))
+	copy(rs, s.ranges)
+	return rs
+}
+
+// CIDRs returns the minimal list of CIDRs covering s in address order.
+func (s IPSet) CIDRs() []parser.CIDRInfo {
+	var cidrs []parser.CIDRInfo
+	s.Each(func(cidr parser.CIDRInfo) bool {
+		cidrs = append(cidrs, cidr)
+		return true
+	})
+	return cidrs
+}
+
+// Each calls fn for each minimal CIDR of s in address order until fn
+// returns false.
+func (s IPSet) Each(fn func(cidr parser.CIDRInfo) bool) {
+	for _, r := range s.ranges {
+		for _, p := range r.Prefixes() {
+			if !fn(parser.FromPrefix(p)) {
+				return
+			}
+		}
+	}
+}
+
+// Subnets returns the first n CIDRs of prefix length bits which are in s,
+// in address order. Families whose addresses are shorter than bits are
+// skipped.
+func (s IPSet) Subnets(bits int, n int) []parser.CIDRInfo {
+	var subnets []parser.CIDRInfo
+	s.Each(func(cidr parser.CIDRInfo) bool {
+		if cidr.Bits() > bits || bits > cidr.NetworkAddr().BitLen() {
+			return true
+		}
+		for a := cidr.NetworkAddr(); a.IsValid() && cidr.ContainsAddr(a); {
+			if len(subnets) >= n {
+				return false
+			}
+			sub := parser.FromPrefix(netip.PrefixFrom(a, bits))
+			subnets = append(subnets, sub)
+			a = sub.Range().To.Next()
+		}
+		return len(subnets) < n
+	})
+	return subnets
+}
+
+// AddrCount returns the number of addresses in s.
+func (s IPSet) AddrCount() *big.Int {
+	n := new(big.Int)
+	for _, r := range s.ranges {
+		n.Add(n, r.AddrCount())
+	}
+	return n
+}
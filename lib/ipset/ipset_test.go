@@ -0,0 +1,153 @@
+package ipset
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+func cidrStrings(s IPSet) []string {
+	var strs []string
+	for _, cidr := range s.CIDRs() {
+		strs = append(strs, cidr.String())
+	}
+	return strs
+}
+
+var exprTests = []struct {
+	expr     string
+	expected []string
+}{
+	{"10.0.0.0/8 - 10.1.0.0/16 + 192.168.0.0/16", []string{
+		"10.0.0.0/16",
+		"10.2.0.0/15",
+		"10.4.0.0/14",
+		"10.8.0.0/13",
+		"10.16.0.0/12",
+		"10.32.0.0/11",
+		"10.64.0.0/10",
+		"10.128.0.0/9",
+		"192.168.0.0/16"}},
+	{"10.0.0.0/25 + 10.0.0.128/25", []string{"10.0.0.0/24"}},
+	{"10.0.0.0/24 + 10.0.0.64/26", []string{"10.0.0.0/24"}},
+	{"10.0.0.0/24 & 10.0.0.128/25", []string{"10.0.0.128/25"}},
+	{"10.0.0.0/24 & 10.0.1.0/24", nil},
+	{"10.0.0.0/30 - 10.0.0.1", []string{"10.0.0.0/32", "10.0.0.2/31"}},
+	{"10.0.0.0/24-(10.0.0.0/25+10.0.0.128/26)", []string{"10.0.0.192/26"}},
+	{"!128.0.0.0/1 & 0.0.0.0/0", []string{"0.0.0.0/1"}},
+	{"!0.0.0.0/0 - ::/1", []string{"8000::/1"}},
+	{"2001:db8::/32 - 2001:db8::/33", []string{"2001:db8:8000::/33"}},
+	{"10.0.0.0/8 + 2001:db8::/32 - 10.0.0.0/8", []string{"2001:db8::/32"}},
+}
+
+func TestParseExpr(t *testing.T) {
+	for _, et := range exprTests {
+		s, e := ParseExpr(et.expr)
+		if e != nil {
+			t.Errorf("ParseExpr(%s) error: %#v", et.expr, e)
+			continue
+		}
+		if actual := cidrStrings(s); !reflect.DeepEqual(actual, et.expected) {
+			t.Errorf("ParseExpr(%s) actual: %v, expected: %v", et.expr, actual, et.expected)
+		}
+	}
+}
+
+func TestParseExprError(t *testing.T) {
+	for _, expr := range []string{"", "10.0.0.0/8 -", "(10.0.0.0/8", "10.0.0.0/33", "10.0.0.0/8 10.1.0.0/16", "+ 10.0.0.0/8"} {
+		if _, e := ParseExpr(expr); e == nil {
+			t.Errorf("ParseExpr(%s) expected error", expr)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	s, _ := ParseExpr("10.0.0.0/8 - 10.1.0.0/16 + 2001:db8::/32")
+	for _, ct := range []struct {
+		addr     string
+		expected bool
+	}{
+		{"10.0.0.1", true},
+		{"10.1.2.3", false},
+		{"10.2.0.0", true},
+		{"11.0.0.0", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+	} {
+		if actual := s.Contains(netip.MustParseAddr(ct.addr)); actual != ct.expected {
+			t.Errorf("Contains(%s) actual: %v, expected: %v", ct.addr, actual, ct.expected)
+		}
+	}
+
+	in, _ := parser.Parse("10.2.0.0/16")
+	over, _ := parser.Parse("10.0.0.0/15")
+	if !s.ContainsCIDR(in) || s.ContainsCIDR(over) {
+		t.Errorf("ContainsCIDR actual: %v %v", s.ContainsCIDR(in), s.ContainsCIDR(over))
+	}
+}
+
+func TestComplement(t *testing.T) {
+	var empty IPSet
+	if !empty.Complement().Equal(All()) || !All().Complement().IsEmpty() {
+		t.Errorf("Complement of empty and all actual: %v %v", empty.Complement(), All().Complement())
+	}
+
+	s, _ := ParseExpr("10.0.0.0/8 + 2001:db8::/32")
+	if !s.Complement().Complement().Equal(s) {
+		t.Errorf("double Complement actual: %v", cidrStrings(s.Complement().Complement()))
+	}
+	if !s.Union(s.Complement()).Equal(All()) || !s.Intersect(s.Complement()).IsEmpty() {
+		t.Errorf("Complement is not disjoint: %v", cidrStrings(s.Complement()))
+	}
+}
+
+func TestEachStop(t *testing.T) {
+	s, _ := ParseExpr("10.0.0.0/30 - 10.0.0.1")
+	n := 0
+	s.Each(func(cidr parser.CIDRInfo) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("Each called %d times, expected: 1", n)
+	}
+}
+
+func TestAddrCount(t *testing.T) {
+	s, _ := ParseExpr("10.0.0.0/24 + 10.0.0.128/25 + 10.0.1.0/30 + 2001:db8::/64")
+	if actual := s.AddrCount().String(); actual != "18446744073709551876" {
+		t.Errorf("AddrCount actual: %s, expected: %s", actual, "18446744073709551876")
+	}
+	if actual := All().AddrCount().String(); actual != "340282366920938463463374607436063178752" {
+		t.Errorf("All AddrCount actual: %s", actual)
+	}
+}
+
+func TestSubnets(t *testing.T) {
+	tests := []struct {
+		expr     string
+		bits     int
+		n        int
+		expected []string
+	}{
+		{"10.0.0.0/22 - 10.0.0.0/24 - 10.0.2.64/26", 24, 3, []string{"10.0.1.0/24", "10.0.3.0/24"}},
+		{"10.0.0.0/22 - 10.0.0.0/24 - 10.0.2.64/26", 26, 3, []string{"10.0.1.0/26", "10.0.1.64/26", "10.0.1.128/26"}},
+		{"10.0.0.0/22 - 10.0.0.0/24 - 10.0.1.0/26", 26, 1, []string{"10.0.1.64/26"}},
+		{"10.0.0.0/24", 23, 1, nil},
+		{"10.0.0.0/24 + 2001:db8::/64", 64, 2, []string{"2001:db8::/64"}},
+		{"255.255.255.0/24", 25, 3, []string{"255.255.255.0/25", "255.255.255.128/25"}},
+	}
+
+	for _, tt := range tests {
+		s, _ := ParseExpr(tt.expr)
+		var actual []string
+		for _, cidr := range s.Subnets(tt.bits, tt.n) {
+			actual = append(actual, cidr.String())
+		}
+		if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("%s /%d actual: %v, expected: %v", tt.expr, tt.bits, actual, tt.expected)
+		}
+	}
+}
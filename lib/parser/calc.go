@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strings"
+)
+
+// AddrToInt returns addr as an unsigned integer.
+func AddrToInt(addr netip.Addr) *big.Int {
+	return u128FromAddr(addr).big()
+}
+
+// AddrFromInt returns the IPv4 address if is4 is true, or the IPv6 address
+// of the unsigned integer n.
+func AddrFromInt(n *big.Int, is4 bool) (netip.Addr, error) {
+	bitLen, family := 128, "IPv6"
+	if is4 {
+		bitLen, family = 32, "IPv4"
+	}
+	u, ok := u128FromBig(n)
+	if !ok || n.BitLen() > bitLen {
+		return netip.Addr{}, fmt.Errorf("%s is out of %s addresses\n", n, family)
+	}
+	return u.addr(is4), nil
+}
+
+// OffsetAddr returns the address n after addr, or before addr if n is
+// negative.
+func OffsetAddr(addr netip.Addr, n *big.Int) (netip.Addr, error) {
+	return AddrFromInt(new(big.Int).Add(AddrToInt(addr), n), addr.Is4())
+}
+
+// Distance returns to minus from as the number of addresses. Both addresses
+// must be of the same family.
+func Distance(from, to netip.Addr) (*big.Int, error) {
+	if from.BitLen() != to.BitLen() {
+		return nil, fmt.Errorf("%s and %s are different families\n", from, to)
+	}
+	return new(big.Int).Sub(AddrToInt(to), AddrToInt(from)), nil
+}
+
+// Host returns the n-th host address of the network counted from 0 at Min,
+// or from -1 at Max if n is negative. IPv6 networks and IPv4 /31 and /32
+// count every address.
+func (cidr CIDRInfo) Host(n *big.Int) (netip.Addr, error) {
+	r := cidr.Range()
+	if cidr.minAddr.IsValid() {
+		r = Range{cidr.minAddr, cidr.maxAddr}
+	}
+
+	base, offset := r.From, n
+	if n.Sign() < 0 {
+		base, offset = r.To, new(big.Int).Add(n, big.NewInt(1))
+	}
+	a, err := OffsetAddr(base, offset)
+	if err != nil || !r.Contains(a) {
+		return netip.Addr{}, fmt.Errorf("host %s is out of %s\n", n, cidr)
+	}
+	return a, nil
+}
+
+// AddrHex returns addr as a hexadecimal integer such as "0x0a000001".
+func AddrHex(addr netip.Addr) string {
+	var buf strings.Builder
+	buf.WriteString("0x")
+	for _, b := range addr.AsSlice() {
+		buf.WriteString(binstr2hexstr(byte2binstr(b)))
+	}
+	return buf.String()
+}
+
+// AddrBinary returns addr in binary, octets of IPv4 are separated by "."
+// and 16 bit groups of IPv6 by ":".
+func AddrBinary(addr netip.Addr) string {
+	b := addr.AsSlice()
+	var buf strings.Builder
+	for i := range b {
+		switch {
+		case i == 0:
+		case addr.Is4():
+			buf.WriteString(".")
+		case i%2 == 0:
+			buf.WriteString(":")
+		}
+		buf.WriteString(byte2binstr(b[i]))
+	}
+	return buf.String()
+}
+
+// MappedAddr returns the IPv4-mapped IPv6 address of an IPv4 addr such as
+// "::ffff:10.0.0.1". An IPv6 addr is returned as is.
+func MappedAddr(addr netip.Addr) netip.Addr {
+	return netip.AddrFrom16(addr.As16())
+}
+
+// ParseAddrForm parses an address in one of these forms:
+//   - dotted IPv4 or IPv6 notation
+//   - decimal integer, which is IPv4 if it fits in 32 bits
+//   - hexadecimal integer with "0x", which is IPv4 if it has at most 8 digits
+//   - binary integer with "0b", which is IPv4 if it has at most 32 digits,
+//     "." and ":" separators are ignored
+func ParseAddrForm(s string) (netip.Addr, error) {
+	if a, err := netip.ParseAddr(s); err == nil {
+		return a, nil
+	}
+
+	lower := strings.ToLower(s)
+	digits, base := lower, 10
+	switch {
+	case strings.HasPrefix(lower, "0x"):
+		digits, base = lower[2:], 16
+	case strings.HasPrefix(lower, "0b"):
+		digits, base = strings.NewReplacer(".", "", ":", "").Replace(lower[2:]), 2
+	}
+	n, ok := new(big.Int).SetString(digits, base)
+	if !ok || digits == "" {
+		return netip.Addr{}, fmt.Errorf("%s is not an address\n", s)
+	}
+
+	is4 := n.BitLen() <= 32
+	switch base {
+	case 16:
+		is4 = len(digits) <= 8
+	case 2:
+		is4 = len(digits) <= 32
+	}
+	return AddrFromInt(n, is4)
+}
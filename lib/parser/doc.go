@@ -0,0 +1,22 @@
+/*
+Package parser parses CIDR strings and calculates their addresses.
+
+Parse returns a CIDRInfo which holds the network, mask, number of hosts,
+first and last usable addresses and broadcast address of an IPv4 or IPv6
+CIDR.
+
+	cidr, err := parser.Parse("192.168.1.0/24")
+	if err != nil {
+		return err
+	}
+	fmt.Println(cidr.Min, cidr.Max, cidr.HostNum) // 192.168.1.1 192.168.1.254 254
+
+CIDRInfo is a value type. It can be compared with Compare and used directly
+in configuration structs, because it implements encoding.TextMarshaler and
+encoding.TextUnmarshaler:
+
+	type Config struct {
+		Allow []parser.CIDRInfo `json:"allow"`
+	}
+*/
+package parser
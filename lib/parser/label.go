@@ -0,0 +1,145 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Attr is a key=value attribute of a labelled CIDR.
+type Attr struct {
+	Key   string
+	Value string
+}
+
+// String returns a as "key=value".
+func (a Attr) String() string {
+	return a.Key + "=" + a.Value
+}
+
+// Columns returns the names of the fields of CIDRInfo which are calculated
+// by Parse, in the order of the csv and tsv columns. A CIDR list which has
+// these columns is calculated again when it is read.
+func Columns() []string {
+	return []string{"source_cidr",
+		"network",
+		"mask",
+		"host_num",
+		"min_address",
+		"max_address",
+		"broadcast",
+		"reverse_zone"}
+}
+
+// ParseLine parses a line of a CIDR list such as
+// "10.20.0.0/16  prod-vpc  owner=netops". The first field is parsed with
+// Parse. Following fields in key=value form are set to Attrs in order and
+// the other fields are joined with a space and set to Label.
+func ParseLine(line string) (CIDRInfo, error) {
+	src, label, attrs := SplitLine(line)
+	if src == "" {
+		return CIDRInfo{}, fmt.Errorf("CIDR is empty\n")
+	}
+
+	cidr, err := Parse(src)
+	if err != nil {
+		return cidr, err
+	}
+	cidr.Label = label
+	cidr.Attrs = attrs
+
+	return cidr, nil
+}
+
+// SplitLine splits a line of a CIDR list into the CIDR, the label and the
+// attributes without parsing the CIDR. See ParseLine.
+func SplitLine(line string) (src string, label string, attrs []Attr) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", "", nil
+	}
+
+	attrs = ParseAttrs(fields[1:])
+	var labels []string
+	for _, f := range fields[1:] {
+		if !isAttr(f) {
+			labels = append(labels, f)
+		}
+	}
+
+	return fields[0], strings.Join(labels, " "), attrs
+}
+
+// ParseAttrs returns the key=value fields of fields in order. The other
+// fields are ignored.
+func ParseAttrs(fields []string) []Attr {
+	var attrs []Attr
+	for _, f := range fields {
+		if isAttr(f) {
+			i := strings.Index(f, "=")
+			attrs = append(attrs, Attr{f[:i], f[i+1:]})
+		}
+	}
+	return attrs
+}
+
+func isAttr(field string) bool {
+	return strings.Index(field, "=") > 0
+}
+
+// Attr returns the value of the attribute key.
+func (cidr CIDRInfo) Attr(key string) (string, bool) {
+	for _, a := range cidr.Attrs {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// AttrsString returns Attrs as space separated key=value pairs. A value
+// which has spaces or quotes is quoted like a Go string, such as
+// key="two words". ParseAttrsString parses it back.
+func (cidr CIDRInfo) AttrsString() string {
+	s := make([]string, len(cidr.Attrs))
+	for i, a := range cidr.Attrs {
+		if strings.ContainsAny(a.Value, " \t\r\n\"") {
+			s[i] = a.Key + "=" + strconv.Quote(a.Value)
+		} else {
+			s[i] = a.String()
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+// ParseAttrsString parses attributes written by AttrsString. Fields which
+// are not in key=value form are ignored.
+func ParseAttrsString(s string) []Attr {
+	var attrs []Attr
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			return attrs
+		}
+
+		field := s
+		if i := strings.IndexAny(s, " \t\r\n"); i >= 0 {
+			field = s[:i]
+		}
+		eq := strings.Index(field, "=")
+		if eq <= 0 {
+			s = s[len(field):]
+			continue
+		}
+
+		key, rest := s[:eq], s[eq+1:]
+		if q, err := strconv.QuotedPrefix(rest); err == nil {
+			v, _ := strconv.Unquote(q)
+			attrs = append(attrs, Attr{key, v})
+			s = rest[len(q):]
+			continue
+		}
+		attrs = append(attrs, Attr{key, field[eq+1:]})
+		s = s[len(field):]
+	}
+}
@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// Next returns the adjacent network of the same prefix length after cidr.
+func (cidr CIDRInfo) Next() (CIDRInfo, error) {
+	a := lastAddr(cidr.prefix).Next()
+	if !a.IsValid() {
+		return CIDRInfo{}, fmt.Errorf("%s has no next network\n", cidr)
+	}
+	return FromPrefix(netip.PrefixFrom(a, cidr.Bits())), nil
+}
+
+// Prev returns the adjacent network of the same prefix length before cidr.
+func (cidr CIDRInfo) Prev() (CIDRInfo, error) {
+	a := cidr.prefix.Addr().Prev()
+	if !a.IsValid() {
+		return CIDRInfo{}, fmt.Errorf("%s has no previous network\n", cidr)
+	}
+	return FromPrefix(netip.PrefixFrom(a, cidr.Bits())), nil
+}
+
+// Parent returns the network of prefix length one less which contains cidr.
+func (cidr CIDRInfo) Parent() (CIDRInfo, error) {
+	return cidr.Supernet(cidr.Bits() - 1)
+}
+
+// Supernet returns the network of prefix length bits which contains cidr.
+// bits must not be greater than the prefix length of cidr.
+func (cidr CIDRInfo) Supernet(bits int) (CIDRInfo, error) {
+	if bits < 0 || bits > cidr.Bits() {
+		return CIDRInfo{}, fmt.Errorf("%s has no supernet of /%d\n", cidr, bits)
+	}
+	return FromPrefix(netip.PrefixFrom(cidr.prefix.Addr(), bits)), nil
+}
+
+// Sibling returns the other half of the parent of cidr, which merges with
+// cidr into the parent.
+func (cidr CIDRInfo) Sibling() (CIDRInfo, error) {
+	parent, err := cidr.Parent()
+	if err != nil {
+		return CIDRInfo{}, fmt.Errorf("%s has no sibling\n", cidr)
+	}
+	if parent.NetworkAddr() == cidr.NetworkAddr() {
+		return cidr.Next()
+	}
+	return cidr.Prev()
+}
+
+// Children returns the two halves of cidr of prefix length one more.
+func (cidr CIDRInfo) Children() ([]CIDRInfo, error) {
+	bits := cidr.Bits() + 1
+	if bits > cidr.prefix.Addr().BitLen() {
+		return nil, fmt.Errorf("%s has no children\n", cidr)
+	}
+	first := FromPrefix(netip.PrefixFrom(cidr.prefix.Addr(), bits))
+	second, err := first.Next()
+	if err != nil {
+		return nil, err
+	}
+	return []CIDRInfo{first, second}, nil
+}
@@ -1,32 +1,45 @@
 package parser
 
 import (
-	"bytes"
 	"fmt"
+	"math"
 	"math/big"
+	"math/bits"
 	"net"
-	"strings"
+	"net/netip"
 )
 
+// Address families returned by CIDRInfo.Family.
 const (
 	TYPE_IPV4 = "ipv4"
 	TYPE_IPV6 = "ipv6"
 )
 
+// CIDRInfo is a parsed CIDR and the addresses calculated from it.
+//
+// The zero value is not a valid CIDR. Use Parse or UnmarshalText to make one.
 type CIDRInfo struct {
-	t         string
-	ipNet     *net.IPNet
-	Network   net.IP     // []byte
-	Mask      net.IPMask // []byte
-	ones      int
-	bits      int
-	SrcCIDR   string
-	HostNum   int
-	Min       net.IP // []byte
-	Max       net.IP // []byte
-	Broadcast net.IP // []byte
+	t             string
+	prefix        netip.Prefix
+	ipNet         *net.IPNet
+	Network       net.IP     // []byte
+	Mask          net.IPMask // []byte
+	ones          int
+	bits          int
+	SrcCIDR       string
+	HostNum       int
+	Min           net.IP // []byte
+	Max           net.IP // []byte
+	Broadcast     net.IP // []byte
+	Label         string // optional name from a labelled CIDR list
+	Attrs         []Attr // optional key=value attributes from a labelled CIDR list
+	minAddr       netip.Addr
+	maxAddr       netip.Addr
+	broadcastAddr netip.Addr
 }
 
+// Parse parses srcCIDR such as "192.168.1.0/24" or "2001:db8::/32" and
+// calculates its addresses. Host bits in srcCIDR are allowed and masked.
 func Parse(srcCIDR string) (CIDRInfo, error) {
 	var cidr CIDRInfo
 
@@ -40,6 +53,14 @@ func Parse(srcCIDR string) (CIDRInfo, error) {
 		return cidr, eType
 	}
 
+	// an IPv4-mapped IPv6 CIDR such as "::ffff:10.0.0.0/104" is the IPv4 CIDR
+	if ones, bits := ipNet.Mask.Size(); t == TYPE_IPV4 && bits == 8*net.IPv6len {
+		if ones < 96 {
+			return cidr, fmt.Errorf("prefix length of IPv4-mapped address %s must be 96 or more\n", srcCIDR)
+		}
+		ipNet = &net.IPNet{IP: ipNet.IP.To4(), Mask: net.CIDRMask(ones-96, 8*net.IPv4len)}
+	}
+
 	cidr.t = t
 	cidr.SrcCIDR = srcCIDR
 	cidr.ipNet = ipNet
@@ -47,9 +68,20 @@ func Parse(srcCIDR string) (CIDRInfo, error) {
 	cidr.Mask = ipNet.Mask
 	cidr.ones, cidr.bits = cidr.Mask.Size()
 
+	addr, _ := netip.AddrFromSlice(ipNet.IP)
+	if cidr.t == TYPE_IPV4 {
+		addr = addr.Unmap()
+	}
+	cidr.prefix = netip.PrefixFrom(addr, cidr.ones)
+
+	// calculate
+	cidr.calcHostNum()
 	if cidr.t == TYPE_IPV4 {
-		// calculate
-		cidr.calcIPv4()
+		if cidr.HostNum > 1 {
+			cidr.calcAddressesV4()
+		}
+	} else {
+		cidr.calcAddressesV6()
 	}
 
 	return cidr, nil
@@ -59,74 +91,62 @@ func getType(ip net.IP) (string, error) {
 	if ip4 := ip.To4(); len(ip4) == net.IPv4len {
 		return TYPE_IPV4, nil
 	} else if ip6 := ip.To16(); len(ip6) == net.IPv6len {
-		// TODO ipv6
-		return "", fmt.Errorf("ip %+v is not IPv4\n", ip)
+		return TYPE_IPV6, nil
 	} else {
-		return "", fmt.Errorf("ip %+v is not IPv4\n", ip)
+		return "", fmt.Errorf("ip %+v is not IPv4 or IPv6\n", ip)
 	}
 }
 
-func (cidr *CIDRInfo) calcIPv4() {
-	// Host Num
-	cidr.calcHostNumV4()
-
-	// Min, Max, Broadcast
-	if cidr.HostNum > 1 {
-		cidr.calcAddressesV4()
+// calcHostNum sets the number of usable hosts. IPv4 excludes the network and
+// broadcast addresses except for /31 and /32, IPv6 counts every address.
+// Counts which overflow int are capped at math.MaxInt.
+func (cidr *CIDRInfo) calcHostNum() {
+	hostBits := cidr.bits - cidr.ones
+	switch {
+	case hostBits == 0:
+		cidr.HostNum = 1
+	case hostBits == 1:
+		cidr.HostNum = 2
+	case hostBits >= bits.UintSize-1:
+		cidr.HostNum = math.MaxInt
+	case cidr.t == TYPE_IPV6:
+		cidr.HostNum = 1 << uint(hostBits)
+	default:
+		cidr.HostNum = 1<<uint(hostBits) - 2
 	}
 }
 
-func (cidr *CIDRInfo) calcHostNumV4() {
-	var hostNum int
-	if cidr.ones == cidr.bits {
-		hostNum = 1
-	} else if cidr.ones == cidr.bits-1 {
-		hostNum = 2
-	} else {
-		hostNum = int(new(big.Int).Exp(big.NewInt(2), big.NewInt(int64(cidr.bits-cidr.ones)), nil).Int64()) - 2
-	}
-	cidr.HostNum = hostNum
-}
-
 func (cidr *CIDRInfo) calcAddressesV4() {
+	network := u128FromAddr(cidr.prefix.Addr())
+	broadcast := network.or(hostMask(cidr.bits - cidr.ones))
+
 	// Broadcast Address
-	cidr.calcBroadCast()
+	cidr.broadcastAddr = broadcast.addr(true)
 
-	// Min IP Address
+	// Min, Max IP Address
 	if cidr.ones == 31 {
-		// XXX
-		cidr.Min = cidr.Network
+		cidr.minAddr = cidr.prefix.Addr()
+		cidr.maxAddr = cidr.broadcastAddr
 	} else {
-		minIp := makeIpV4(cidr.Network)
-		minIp[3] += 1
-		cidr.Min = minIp
+		cidr.minAddr = network.addOne().addr(true)
+		cidr.maxAddr = broadcast.subOne().addr(true)
 	}
 
-	// Max IP Address
-	if cidr.ones == 31 {
-		// XXX
-		cidr.Max = cidr.Broadcast
-	} else {
-		maxIp := makeIpV4(cidr.Broadcast)
-		maxIp[3] -= 1
-		cidr.Max = maxIp
-	}
+	cidr.Broadcast = cidr.broadcastAddr.AsSlice()
+	cidr.Min = cidr.minAddr.AsSlice()
+	cidr.Max = cidr.maxAddr.AsSlice()
 }
 
-func (cidr *CIDRInfo) calcBroadCast() {
-	var buf bytes.Buffer
-	for _, octet := range cidr.Network {
-		buf.WriteString(byte2binstr(octet))
-	}
+// calcAddressesV6 sets Min and Max to the first and the last address of the
+// prefix. IPv6 has no broadcast address.
+func (cidr *CIDRInfo) calcAddressesV6() {
+	network := u128FromAddr(cidr.prefix.Addr())
 
-	bcBinStr := buf.String()[:cidr.ones] + strings.Repeat("1", cidr.bits-cidr.ones)
+	cidr.minAddr = cidr.prefix.Addr()
+	cidr.maxAddr = network.or(hostMask(cidr.bits - cidr.ones)).addr(false)
 
-	broad := make([]byte, 4)
-	for i, f, t := 0, 0, 8; i < 4; i, f, t = i+1, f+8, t+8 {
-		broad[i] = binstr2byte(bcBinStr[f:t])
-	}
-
-	cidr.Broadcast = makeIpV4(broad)
+	cidr.Min = cidr.minAddr.AsSlice()
+	cidr.Max = cidr.maxAddr.AsSlice()
 }
 
 func byte2binstr(b byte) string {
@@ -149,13 +169,168 @@ func binstr2hexstr(binstr string) string {
 	return fmt.Sprintf("%02x", binstr2byte(binstr))
 }
 
-func makeIpV4(src []byte) net.IP {
-	dst := make(net.IP, net.IPv4len)
-	copy(dst, src)
+// Contains reports whether srcIP is in the network.
+func (cidr CIDRInfo) Contains(srcIP string) bool {
+	return cidr.ipNet.Contains(net.ParseIP(srcIP))
+}
 
-	return dst
+// ContainsAddr reports whether addr is in the network. IPv4-mapped IPv6
+// addresses are treated as IPv4.
+func (cidr CIDRInfo) ContainsAddr(addr netip.Addr) bool {
+	if cidr.t == TYPE_IPV4 {
+		addr = addr.Unmap()
+	}
+	return cidr.prefix.Contains(addr)
 }
 
-func (cidr *CIDRInfo) Contains(srcIP string) bool {
-	return cidr.ipNet.Contains(net.ParseIP(srcIP))
+// Prefix returns the masked network prefix.
+func (cidr CIDRInfo) Prefix() netip.Prefix {
+	return cidr.prefix
+}
+
+// NetworkAddr returns the network address.
+func (cidr CIDRInfo) NetworkAddr() netip.Addr {
+	return cidr.prefix.Addr()
+}
+
+// MinAddr returns the first usable address, or the zero Addr if Min is nil.
+func (cidr CIDRInfo) MinAddr() netip.Addr {
+	return cidr.minAddr
+}
+
+// MaxAddr returns the last usable address, or the zero Addr if Max is nil.
+func (cidr CIDRInfo) MaxAddr() netip.Addr {
+	return cidr.maxAddr
+}
+
+// BroadcastAddr returns the broadcast address, or the zero Addr if Broadcast
+// is nil.
+func (cidr CIDRInfo) BroadcastAddr() netip.Addr {
+	return cidr.broadcastAddr
+}
+
+// AddrCount returns the number of addresses of the network, including the
+// network and broadcast addresses.
+func (cidr CIDRInfo) AddrCount() *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(cidr.bits-cidr.ones))
+}
+
+// HostCount is HostNum which is not capped for large IPv6 networks.
+func (cidr CIDRInfo) HostCount() *big.Int {
+	n := cidr.AddrCount()
+	if cidr.t == TYPE_IPV4 && cidr.bits-cidr.ones > 1 {
+		n.Sub(n, big.NewInt(2))
+	}
+	return n
+}
+
+// Class returns the classful network class "A" to "E" of the network
+// address of an IPv4 CIDR, or "" for IPv6.
+func (cidr CIDRInfo) Class() string {
+	if cidr.t != TYPE_IPV4 {
+		return ""
+	}
+
+	b := cidr.prefix.Addr().As4()
+	switch {
+	case b[0] < 128:
+		return "A"
+	case b[0] < 192:
+		return "B"
+	case b[0] < 224:
+		return "C"
+	case b[0] < 240:
+		return "D"
+	}
+	return "E"
+}
+
+// Family returns TYPE_IPV4 or TYPE_IPV6.
+func (cidr CIDRInfo) Family() string {
+	return cidr.t
+}
+
+// Bits returns the prefix length.
+func (cidr CIDRInfo) Bits() int {
+	return cidr.ones
+}
+
+// IPNet returns the network as a *net.IPNet. The result is a copy.
+func (cidr CIDRInfo) IPNet() *net.IPNet {
+	if cidr.ipNet == nil {
+		return nil
+	}
+	return &net.IPNet{
+		IP:   append(net.IP(nil), cidr.ipNet.IP...),
+		Mask: append(net.IPMask(nil), cidr.ipNet.Mask...),
+	}
+}
+
+// IsValid reports whether cidr was made by Parse or UnmarshalText.
+func (cidr CIDRInfo) IsValid() bool {
+	return cidr.prefix.IsValid()
+}
+
+// Compare returns an integer comparing two CIDRs. IPv4 sorts before IPv6,
+// then CIDRs are ordered by network address and then by prefix length, so
+// a network sorts before its subnets. The result is 0 if cidr and other are
+// the same network, -1 if cidr sorts first and +1 otherwise.
+func (cidr CIDRInfo) Compare(other CIDRInfo) int {
+	if c := cidr.prefix.Addr().Compare(other.prefix.Addr()); c != 0 {
+		return c
+	}
+	switch {
+	case cidr.ones < other.ones:
+		return -1
+	case cidr.ones > other.ones:
+		return 1
+	}
+	return 0
+}
+
+// Less reports whether cidr sorts before other.
+func (cidr CIDRInfo) Less(other CIDRInfo) bool {
+	return cidr.Compare(other) < 0
+}
+
+// Equal reports whether cidr and other are the same network.
+func (cidr CIDRInfo) Equal(other CIDRInfo) bool {
+	return cidr.Compare(other) == 0
+}
+
+// String returns the network in canonical CIDR notation such as
+// "192.168.1.0/24", or "invalid CIDR" for the zero value.
+func (cidr CIDRInfo) String() string {
+	if !cidr.IsValid() {
+		return "invalid CIDR"
+	}
+	return cidr.prefix.String()
+}
+
+// MarshalText implements encoding.TextMarshaler. It returns SrcCIDR as given
+// to Parse, so host bits are kept. The zero value marshals to empty text.
+func (cidr CIDRInfo) MarshalText() ([]byte, error) {
+	if !cidr.IsValid() {
+		return []byte(""), nil
+	}
+	if cidr.SrcCIDR != "" {
+		return []byte(cidr.SrcCIDR), nil
+	}
+	return []byte(cidr.prefix.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler with Parse. Empty text
+// sets cidr to the zero value.
+func (cidr *CIDRInfo) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*cidr = CIDRInfo{}
+		return nil
+	}
+
+	c, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*cidr = c
+	return nil
 }
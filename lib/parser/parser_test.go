@@ -1,8 +1,13 @@
 package parser
 
 import (
+	"bytes"
+	"encoding/json"
+	"math/big"
 	"net"
+	"net/netip"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -315,6 +320,16 @@ var validateTests = []struct {
 		Min:       nil,
 		Max:       nil,
 		Broadcast: nil}},
+	{"::ffff:10.0.0.0/104", CIDRInfo{t: TYPE_IPV4,
+		HostNum:   16777214,
+		Min:       net.IPv4(10, 0, 0, 1).To4(),
+		Max:       net.IPv4(10, 255, 255, 254).To4(),
+		Broadcast: net.IPv4(10, 255, 255, 255).To4()}},
+	{"::ffff:192.168.1.1/128", CIDRInfo{t: TYPE_IPV4,
+		HostNum:   1,
+		Min:       nil,
+		Max:       nil,
+		Broadcast: nil}},
 }
 
 func TestParse(t *testing.T) {
@@ -339,6 +354,562 @@ func TestParse(t *testing.T) {
 		if !reflect.DeepEqual(ci.Max, vt.expected.Max) {
 			t.Errorf("Parse(%v) error Max, actual: %s, expected: %s", vt.srcCIDR, ci.Max, vt.expected.Max)
 		}
+		if !ci.IsValid() || len(ci.ReverseZones()) == 0 {
+			t.Errorf("Parse(%v) invalid prefix: %s", vt.srcCIDR, ci.Prefix())
+		}
+	}
+
+	if ci, _ := Parse("::ffff:10.0.0.0/104"); ci.String() != "10.0.0.0/8" || len(ci.Mask) != net.IPv4len {
+		t.Errorf("Parse IPv4-mapped actual: %s %s", ci, ci.Mask)
+	}
+	if _, e := Parse("::ffff:10.0.0.0/80"); e == nil {
+		t.Errorf("Parse IPv4-mapped /80 expected error")
 	}
+}
+
+var validateV6Tests = []struct {
+	srcCIDR  string
+	expected CIDRInfo
+}{
+	{"2001:db8::1/64", CIDRInfo{t: TYPE_IPV6,
+		HostNum: 9223372036854775807,
+		Min:     net.ParseIP("2001:db8::"),
+		Max:     net.ParseIP("2001:db8::ffff:ffff:ffff:ffff")}},
+	{"2001:db8::/120", CIDRInfo{t: TYPE_IPV6,
+		HostNum: 256,
+		Min:     net.ParseIP("2001:db8::"),
+		Max:     net.ParseIP("2001:db8::ff")}},
+	{"2001:db8::/127", CIDRInfo{t: TYPE_IPV6,
+		HostNum: 2,
+		Min:     net.ParseIP("2001:db8::"),
+		Max:     net.ParseIP("2001:db8::1")}},
+	{"2001:db8::1/128", CIDRInfo{t: TYPE_IPV6,
+		HostNum: 1,
+		Min:     net.ParseIP("2001:db8::1"),
+		Max:     net.ParseIP("2001:db8::1")}},
+}
+
+func TestParseV6(t *testing.T) {
+	for _, vt := range validateV6Tests {
+		ci, e := Parse(vt.srcCIDR)
+		if e != nil {
+			t.Errorf("Parse error: %#v", e)
+		}
+
+		if ci.t != vt.expected.t {
+			t.Errorf("Parse(%v) error t, actual: %s, expected: %s", vt.srcCIDR, ci.t, vt.expected.t)
+		}
+		if ci.HostNum != vt.expected.HostNum {
+			t.Errorf("Parse(%v) error HostNum, actual: %d, expected: %d", vt.srcCIDR, ci.HostNum, vt.expected.HostNum)
+		}
+		if !ci.Min.Equal(vt.expected.Min) {
+			t.Errorf("Parse(%v) error Min, actual: %s, expected: %s", vt.srcCIDR, ci.Min, vt.expected.Min)
+		}
+		if !ci.Max.Equal(vt.expected.Max) {
+			t.Errorf("Parse(%v) error Max, actual: %s, expected: %s", vt.srcCIDR, ci.Max, vt.expected.Max)
+		}
+		if ci.Broadcast != nil {
+			t.Errorf("Parse(%v) error Broadcast, actual: %s, expected: nil", vt.srcCIDR, ci.Broadcast)
+		}
+	}
+}
+
+func TestAddrAccessors(t *testing.T) {
+	for _, vt := range validateTests {
+		ci, _ := Parse(vt.srcCIDR)
+
+		if ci.Prefix().String() != ci.ipNet.String() {
+			t.Errorf("Parse(%v) error Prefix, actual: %s, expected: %s", vt.srcCIDR, ci.Prefix(), ci.ipNet)
+		}
+		for _, a := range []struct {
+			name   string
+			addr   netip.Addr
+			expect net.IP
+		}{
+			{"MinAddr", ci.MinAddr(), vt.expected.Min},
+			{"MaxAddr", ci.MaxAddr(), vt.expected.Max},
+			{"BroadcastAddr", ci.BroadcastAddr(), vt.expected.Broadcast},
+		} {
+			if !reflect.DeepEqual(net.IP(a.addr.AsSlice()), a.expect) {
+				t.Errorf("Parse(%v) error %s, actual: %s, expected: %s", vt.srcCIDR, a.name, a.addr, a.expect)
+			}
+		}
+	}
+}
+
+func TestContainsAddr(t *testing.T) {
+	ci, _ := Parse("192.168.1.0/24")
+	for _, ct := range []struct {
+		ip       string
+		expected bool
+	}{
+		{"192.168.1.1", true},
+		{"192.168.2.1", false},
+		{"::ffff:192.168.1.10", true},
+	} {
+		if actual := ci.ContainsAddr(netip.MustParseAddr(ct.ip)); actual != ct.expected {
+			t.Errorf("ContainsAddr(%s) actual: %v, expected: %v", ct.ip, actual, ct.expected)
+		}
+		if actual := ci.Contains(ct.ip); actual != ct.expected {
+			t.Errorf("Contains(%s) actual: %v, expected: %v", ct.ip, actual, ct.expected)
+		}
+	}
+}
 
+// legacyCalcIPv4 is the string based calculation which Parse used before
+// switching to integer math. It is kept to compare in benchmarks.
+func legacyCalcIPv4(network net.IP, ones, bits int) (min, max, broadcast net.IP) {
+	var buf bytes.Buffer
+	for _, octet := range network {
+		buf.WriteString(byte2binstr(octet))
+	}
+
+	bcBinStr := buf.String()[:ones] + strings.Repeat("1", bits-ones)
+
+	broadcast = make(net.IP, net.IPv4len)
+	for i, f, t := 0, 0, 8; i < 4; i, f, t = i+1, f+8, t+8 {
+		broadcast[i] = binstr2byte(bcBinStr[f:t])
+	}
+
+	min = make(net.IP, net.IPv4len)
+	copy(min, network)
+	min[3] += 1
+	max = make(net.IP, net.IPv4len)
+	copy(max, broadcast)
+	max[3] -= 1
+
+	return min, max, broadcast
+}
+
+func TestLegacyCalcIPv4(t *testing.T) {
+	for _, vt := range validateTests {
+		ci, _ := Parse(vt.srcCIDR)
+		if ci.ones >= 31 {
+			continue
+		}
+
+		min, max, broadcast := legacyCalcIPv4(ci.Network, ci.ones, ci.bits)
+		if !min.Equal(ci.Min) || !max.Equal(ci.Max) || !broadcast.Equal(ci.Broadcast) {
+			t.Errorf("legacyCalcIPv4(%v) actual: %s %s %s, expected: %s %s %s", vt.srcCIDR, min, max, broadcast, ci.Min, ci.Max, ci.Broadcast)
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Parse("192.168.1.0/20")
+	}
+}
+
+func BenchmarkCalcAddressesV4(b *testing.B) {
+	ci, _ := Parse("192.168.1.0/20")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ci.calcAddressesV4()
+	}
+}
+
+func BenchmarkCalcAddressesV4Legacy(b *testing.B) {
+	ci, _ := Parse("192.168.1.0/20")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		legacyCalcIPv4(ci.Network, ci.ones, ci.bits)
+	}
+}
+
+func BenchmarkParseV6(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Parse("2001:db8::/48")
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	ci, _ := Parse("2001:db8::1/48")
+
+	if ci.Family() != TYPE_IPV6 {
+		t.Errorf("Family actual: %s, expected: %s", ci.Family(), TYPE_IPV6)
+	}
+	if ci.Bits() != 48 {
+		t.Errorf("Bits actual: %d, expected: %d", ci.Bits(), 48)
+	}
+	if ci.String() != "2001:db8::/48" {
+		t.Errorf("String actual: %s, expected: %s", ci.String(), "2001:db8::/48")
+	}
+
+	n := ci.IPNet()
+	n.IP[0] = 0
+	if ci.IPNet().String() != "2001:db8::/48" {
+		t.Errorf("IPNet is not a copy: %s", ci.IPNet())
+	}
+
+	var zero CIDRInfo
+	if zero.IsValid() || zero.String() != "invalid CIDR" || zero.IPNet() != nil {
+		t.Errorf("zero value actual: %v %s %v", zero.IsValid(), zero.String(), zero.IPNet())
+	}
+}
+
+var compareTests = []struct {
+	a        string
+	b        string
+	expected int
+}{
+	{"10.0.0.0/8", "10.0.0.0/8", 0},
+	{"10.0.0.1/8", "10.0.0.0/8", 0},
+	{"10.0.0.0/8", "10.0.0.0/16", -1},
+	{"10.1.0.0/16", "10.0.0.0/8", 1},
+	{"9.0.0.0/8", "10.0.0.0/8", -1},
+	{"192.168.1.0/24", "::/0", -1},
+	{"2001:db8::/32", "2001:db8::/48", -1},
+}
+
+func TestCompare(t *testing.T) {
+	for _, ct := range compareTests {
+		a, _ := Parse(ct.a)
+		b, _ := Parse(ct.b)
+
+		if actual := a.Compare(b); actual != ct.expected {
+			t.Errorf("Compare(%s, %s) actual: %d, expected: %d", ct.a, ct.b, actual, ct.expected)
+		}
+		if actual := a.Less(b); actual != (ct.expected < 0) {
+			t.Errorf("Less(%s, %s) actual: %v", ct.a, ct.b, actual)
+		}
+		if actual := a.Equal(b); actual != (ct.expected == 0) {
+			t.Errorf("Equal(%s, %s) actual: %v", ct.a, ct.b, actual)
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	type config struct {
+		Allow []CIDRInfo `json:"allow"`
+		Deny  CIDRInfo   `json:"deny"`
+	}
+
+	src := `{"allow":["192.168.1.5/24","2001:db8::/32"],"deny":""}`
+	var c config
+	if e := json.Unmarshal([]byte(src), &c); e != nil {
+		t.Fatalf("Unmarshal error: %#v", e)
+	}
+	if len(c.Allow) != 2 || c.Allow[0].HostNum != 254 || c.Allow[1].Family() != TYPE_IPV6 || c.Deny.IsValid() {
+		t.Errorf("Unmarshal actual: %+v", c)
+	}
+
+	b, e := json.Marshal(c)
+	if e != nil {
+		t.Fatalf("Marshal error: %#v", e)
+	}
+	if string(b) != src {
+		t.Errorf("Marshal actual: %s, expected: %s", b, src)
+	}
+
+	if e := json.Unmarshal([]byte(`{"deny":"192.168.1.0/33"}`), &c); e == nil {
+		t.Errorf("Unmarshal invalid CIDR expected error")
+	}
+}
+
+var rangeTests = []struct {
+	from     string
+	to       string
+	expected []string
+}{
+	{"10.0.0.0", "10.0.0.255", []string{"10.0.0.0/24"}},
+	{"10.0.0.1", "10.0.0.6", []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"}},
+	{"0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0"}},
+	{"255.255.255.255", "255.255.255.255", []string{"255.255.255.255/32"}},
+	{"2001:db8::", "2001:db8::1:0", []string{"2001:db8::/112", "2001:db8::1:0/128"}},
+	{"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", []string{"::/0"}},
+}
+
+func TestRangePrefixes(t *testing.T) {
+	for _, rt := range rangeTests {
+		r, e := NewRange(netip.MustParseAddr(rt.from), netip.MustParseAddr(rt.to))
+		if e != nil {
+			t.Errorf("NewRange(%s, %s) error: %#v", rt.from, rt.to, e)
+			continue
+		}
+
+		var actual []string
+		for _, c := range r.CIDRs() {
+			actual = append(actual, c.String())
+		}
+		if !reflect.DeepEqual(actual, rt.expected) {
+			t.Errorf("Range(%s).CIDRs actual: %v, expected: %v", r, actual, rt.expected)
+		}
+	}
+
+	if _, e := NewRange(netip.MustParseAddr("10.0.0.2"), netip.MustParseAddr("10.0.0.1")); e == nil {
+		t.Errorf("NewRange reversed expected error")
+	}
+	if _, e := NewRange(netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("::1")); e == nil {
+		t.Errorf("NewRange mixed families expected error")
+	}
+}
+
+func TestCIDRRange(t *testing.T) {
+	ci, _ := Parse("192.168.1.10/24")
+	if r := ci.Range(); r.String() != "192.168.1.0-192.168.1.255" {
+		t.Errorf("Range actual: %s", r)
+	}
+}
+
+var parseLineTests = []struct {
+	line  string
+	cidr  string
+	label string
+	attrs []Attr
+}{
+	{"10.20.0.0/16", "10.20.0.0/16", "", nil},
+	{"  10.20.0.0/16  prod-vpc  owner=netops ", "10.20.0.0/16", "prod-vpc", []Attr{{"owner", "netops"}}},
+	{"10.20.0.0/16\tprod vpc\tenv=prod owner=", "10.20.0.0/16", "prod vpc", []Attr{{"env", "prod"}, {"owner", ""}}},
+	{"2001:db8::/32 =x", "2001:db8::/32", "=x", nil},
+}
+
+func TestParseLine(t *testing.T) {
+	for _, pt := range parseLineTests {
+		ci, e := ParseLine(pt.line)
+		if e != nil {
+			t.Errorf("ParseLine(%q) error: %#v", pt.line, e)
+			continue
+		}
+		if ci.SrcCIDR != pt.cidr || ci.Label != pt.label || !reflect.DeepEqual(ci.Attrs, pt.attrs) {
+			t.Errorf("ParseLine(%q) actual: %s %q %v, expected: %s %q %v", pt.line, ci.SrcCIDR, ci.Label, ci.Attrs, pt.cidr, pt.label, pt.attrs)
+		}
+	}
+
+	ci, _ := ParseLine("10.0.0.0/8 corp owner=netops env=prod")
+	if v, ok := ci.Attr("env"); !ok || v != "prod" {
+		t.Errorf("Attr(env) actual: %s %v", v, ok)
+	}
+	if _, ok := ci.Attr("none"); ok {
+		t.Errorf("Attr(none) found")
+	}
+	if ci.AttrsString() != "owner=netops env=prod" {
+		t.Errorf("AttrsString actual: %s", ci.AttrsString())
+	}
+
+	ci.Attrs = []Attr{{"owner", "net ops"}, {"note", `say "hi"`}, {"env", "prod"}}
+	s := ci.AttrsString()
+	if s != `owner="net ops" note="say \"hi\"" env=prod` {
+		t.Errorf("AttrsString quoted actual: %s", s)
+	}
+	if attrs := ParseAttrsString(s + " stray"); !reflect.DeepEqual(attrs, ci.Attrs) {
+		t.Errorf("ParseAttrsString(%q) actual: %v, expected: %v", s, attrs, ci.Attrs)
+	}
+
+	for _, line := range []string{"", "   ", "corp 10.0.0.0/8"} {
+		if _, e := ParseLine(line); e == nil {
+			t.Errorf("ParseLine(%q) expected error", line)
+		}
+	}
+}
+
+func TestCounts(t *testing.T) {
+	for _, ct := range []struct {
+		cidr  string
+		addrs string
+		hosts string
+		class string
+	}{
+		{"10.0.0.0/8", "16777216", "16777214", "A"},
+		{"172.16.0.0/31", "2", "2", "B"},
+		{"192.168.1.1/32", "1", "1", "C"},
+		{"224.0.0.0/4", "268435456", "268435454", "D"},
+		{"240.0.0.0/4", "268435456", "268435454", "E"},
+		{"2001:db8::/32", "79228162514264337593543950336", "79228162514264337593543950336", ""},
+	} {
+		ci, _ := Parse(ct.cidr)
+		if ci.AddrCount().String() != ct.addrs || ci.HostCount().String() != ct.hosts || ci.Class() != ct.class {
+			t.Errorf("Parse(%s) actual: %s %s %s, expected: %s %s %s", ct.cidr, ci.AddrCount(), ci.HostCount(), ci.Class(), ct.addrs, ct.hosts, ct.class)
+		}
+		if ci.t == TYPE_IPV4 && ci.HostCount().Int64() != int64(ci.HostNum) {
+			t.Errorf("Parse(%s) HostCount %s differs from HostNum %d", ct.cidr, ci.HostCount(), ci.HostNum)
+		}
+	}
+}
+
+var reverseZoneTests = []struct {
+	cidr      string
+	zones     []string
+	classless string
+}{
+	{"192.168.1.0/24", []string{"1.168.192.in-addr.arpa"}, ""},
+	{"192.168.0.0/16", []string{"168.192.in-addr.arpa"}, ""},
+	{"192.168.4.0/22", []string{"4.168.192.in-addr.arpa", "5.168.192.in-addr.arpa", "6.168.192.in-addr.arpa", "7.168.192.in-addr.arpa"}, ""},
+	{"10.0.0.0/7", []string{"10.in-addr.arpa", "11.in-addr.arpa"}, ""},
+	{"192.168.1.64/26", []string{"1.168.192.in-addr.arpa"}, "64/26.1.168.192.in-addr.arpa"},
+	{"192.168.1.7/32", []string{"1.168.192.in-addr.arpa"}, ""},
+	{"0.0.0.0/0", []string{"in-addr.arpa"}, ""},
+	{"2001:db8::/32", []string{"8.b.d.0.1.0.0.2.ip6.arpa"}, ""},
+	{"2001:db8::/31", []string{"8.b.d.0.1.0.0.2.ip6.arpa", "9.b.d.0.1.0.0.2.ip6.arpa"}, ""},
+	{"2001:db8:ab00::/40", []string{"b.a.8.b.d.0.1.0.0.2.ip6.arpa"}, ""},
+	{"2001:db8:ab00::/39", []string{"a.a.8.b.d.0.1.0.0.2.ip6.arpa", "b.a.8.b.d.0.1.0.0.2.ip6.arpa"}, ""},
+}
+
+func TestReverseZones(t *testing.T) {
+	for _, rt := range reverseZoneTests {
+		ci, _ := Parse(rt.cidr)
+		if actual := ci.ReverseZones(); !reflect.DeepEqual(actual, rt.zones) {
+			t.Errorf("ReverseZones(%s) actual: %v, expected: %v", rt.cidr, actual, rt.zones)
+		}
+		if actual := ci.ClasslessZone(); actual != rt.classless {
+			t.Errorf("ClasslessZone(%s) actual: %s, expected: %s", rt.cidr, actual, rt.classless)
+		}
+	}
+
+	if actual := ReverseName(netip.MustParseAddr("2001:db8::1")); actual != "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa" {
+		t.Errorf("ReverseName actual: %s", actual)
+	}
+	if actual := ReverseName(netip.MustParseAddr("::ffff:192.168.1.5")); actual != "5.1.168.192.in-addr.arpa" {
+		t.Errorf("ReverseName actual: %s", actual)
+	}
+}
+
+func TestCalc(t *testing.T) {
+	a := netip.MustParseAddr("10.0.0.1")
+	if b, err := OffsetAddr(a, big.NewInt(300)); err != nil || b.String() != "10.0.1.45" {
+		t.Errorf("OffsetAddr actual: %s %v", b, err)
+	}
+	if b, err := OffsetAddr(netip.MustParseAddr("0.0.0.1"), big.NewInt(-2)); err == nil {
+		t.Errorf("OffsetAddr under 0.0.0.0 expected error, actual: %s", b)
+	}
+	if b, err := OffsetAddr(netip.MustParseAddr("2001:db8::ffff"), big.NewInt(1)); err != nil || b.String() != "2001:db8::1:0" {
+		t.Errorf("OffsetAddr v6 actual: %s %v", b, err)
+	}
+	if d, err := Distance(a, netip.MustParseAddr("10.0.1.0")); err != nil || d.String() != "255" {
+		t.Errorf("Distance actual: %s %v", d, err)
+	}
+	if d, err := Distance(netip.MustParseAddr("10.0.1.0"), a); err != nil || d.String() != "-255" {
+		t.Errorf("Distance reversed actual: %s %v", d, err)
+	}
+	if _, err := Distance(a, netip.MustParseAddr("::1")); err == nil {
+		t.Errorf("Distance of different families expected error")
+	}
+
+	hostTests := []struct {
+		cidr     string
+		n        int64
+		expected string
+	}{
+		{"10.0.0.0/24", 0, "10.0.0.1"},
+		{"10.0.0.0/24", 9, "10.0.0.10"},
+		{"10.0.0.0/24", -1, "10.0.0.254"},
+		{"10.0.0.0/24", -254, "10.0.0.1"},
+		{"10.0.0.0/24", 254, ""},
+		{"10.0.0.0/24", -255, ""},
+		{"10.0.0.5/32", 0, "10.0.0.5"},
+		{"2001:db8::/64", -1, "2001:db8::ffff:ffff:ffff:ffff"},
+	}
+	for _, tt := range hostTests {
+		c, _ := Parse(tt.cidr)
+		h, err := c.Host(big.NewInt(tt.n))
+		if tt.expected == "" {
+			if err == nil {
+				t.Errorf("%s Host(%d) expected error, actual: %s", tt.cidr, tt.n, h)
+			}
+			continue
+		}
+		if err != nil || h.String() != tt.expected {
+			t.Errorf("%s Host(%d) actual: %s %v, expected: %s", tt.cidr, tt.n, h, err, tt.expected)
+		}
+	}
+
+	if s := AddrHex(a); s != "0x0a000001" {
+		t.Errorf("AddrHex actual: %s", s)
+	}
+	if s := AddrBinary(a); s != "00001010.00000000.00000000.00000001" {
+		t.Errorf("AddrBinary actual: %s", s)
+	}
+	if s := AddrBinary(netip.MustParseAddr("ff00::")); !strings.HasPrefix(s, "1111111100000000:0000000000000000:") {
+		t.Errorf("AddrBinary v6 actual: %s", s)
+	}
+	if s := MappedAddr(a).String(); s != "::ffff:10.0.0.1" {
+		t.Errorf("MappedAddr actual: %s", s)
+	}
+	if s := AddrToInt(a).String(); s != "167772161" {
+		t.Errorf("AddrToInt actual: %s", s)
+	}
+
+	formTests := []struct {
+		src      string
+		expected string
+	}{
+		{"10.0.0.1", "10.0.0.1"},
+		{"167772161", "10.0.0.1"},
+		{"0x0a000001", "10.0.0.1"},
+		{"0X0A000001", "10.0.0.1"},
+		{"0b00001010.00000000.00000000.00000001", "10.0.0.1"},
+		{"4294967296", "::1:0:0"},
+		{"0x00000000000000000000000000000001", "::1"},
+		{"::ffff:10.0.0.1", "::ffff:10.0.0.1"},
+		{"0x", ""},
+		{"foo", ""},
+		{"-1", ""},
+	}
+	for _, tt := range formTests {
+		a, err := ParseAddrForm(tt.src)
+		if tt.expected == "" {
+			if err == nil {
+				t.Errorf("ParseAddrForm(%s) expected error, actual: %s", tt.src, a)
+			}
+			continue
+		}
+		if err != nil || a.String() != tt.expected {
+			t.Errorf("ParseAddrForm(%s) actual: %s %v, expected: %s", tt.src, a, err, tt.expected)
+		}
+	}
+}
+
+func TestNavigate(t *testing.T) {
+	tests := []struct {
+		cidr     string
+		nav      func(c CIDRInfo) (CIDRInfo, error)
+		expected string
+	}{
+		{"10.0.1.0/24", CIDRInfo.Next, "10.0.2.0/24"},
+		{"10.0.1.0/24", CIDRInfo.Prev, "10.0.0.0/24"},
+		{"10.0.1.0/24", CIDRInfo.Parent, "10.0.0.0/23"},
+		{"10.0.1.0/24", CIDRInfo.Sibling, "10.0.0.0/24"},
+		{"10.0.2.0/24", CIDRInfo.Sibling, "10.0.3.0/24"},
+		{"10.0.1.5/24", CIDRInfo.Next, "10.0.2.0/24"},
+		{"255.255.255.0/24", CIDRInfo.Next, ""},
+		{"0.0.0.0/24", CIDRInfo.Prev, ""},
+		{"0.0.0.0/0", CIDRInfo.Parent, ""},
+		{"0.0.0.0/0", CIDRInfo.Sibling, ""},
+		{"255.255.255.255/32", CIDRInfo.Sibling, "255.255.255.254/32"},
+		{"2001:db8::/64", CIDRInfo.Next, "2001:db8:0:1::/64"},
+		{"2001:db8:0:1::/64", CIDRInfo.Sibling, "2001:db8::/64"},
+		{"2001:db8::/32", CIDRInfo.Parent, "2001:db8::/31"},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128", CIDRInfo.Next, ""},
+	}
+	for _, tt := range tests {
+		c, _ := Parse(tt.cidr)
+		actual, err := tt.nav(c)
+		if tt.expected == "" {
+			if err == nil {
+				t.Errorf("%s expected error, actual: %s", tt.cidr, actual)
+			}
+			continue
+		}
+		if err != nil || actual.String() != tt.expected {
+			t.Errorf("%s actual: %s %v, expected: %s", tt.cidr, actual, err, tt.expected)
+		}
+	}
+
+	c, _ := Parse("10.0.1.0/24")
+	if s, err := c.Supernet(16); err != nil || s.String() != "10.0.0.0/16" {
+		t.Errorf("Supernet(16) actual: %s %v", s, err)
+	}
+	if s, err := c.Supernet(24); err != nil || s.String() != "10.0.1.0/24" {
+		t.Errorf("Supernet(24) actual: %s %v", s, err)
+	}
+	if s, err := c.Supernet(25); err == nil {
+		t.Errorf("Supernet(25) expected error, actual: %s", s)
+	}
+	if cs, err := c.Children(); err != nil || len(cs) != 2 || cs[0].String() != "10.0.1.0/25" || cs[1].String() != "10.0.1.128/25" {
+		t.Errorf("Children actual: %v %v", cs, err)
+	}
+	h, _ := Parse("10.0.1.1/32")
+	if cs, err := h.Children(); err == nil {
+		t.Errorf("Children of /32 expected error, actual: %v", cs)
+	}
 }
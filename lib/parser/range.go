@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+)
+
+// Range is an inclusive range of addresses of one family.
+type Range struct {
+	From netip.Addr
+	To   netip.Addr
+}
+
+// Range returns the addresses of the network from the network address to
+// the last address, including network and broadcast addresses.
+func (cidr CIDRInfo) Range() Range {
+	return Range{cidr.prefix.Addr(), lastAddr(cidr.prefix)}
+}
+
+// FromPrefix returns the CIDRInfo of p. It panics if p is invalid.
+func FromPrefix(p netip.Prefix) CIDRInfo {
+	cidr, err := Parse(p.Masked().String())
+	if err != nil {
+		panic(err)
+	}
+	return cidr
+}
+
+// NewRange returns the range from from to to. Both addresses must be of the
+// same family and from must not be greater than to.
+func NewRange(from, to netip.Addr) (Range, error) {
+	if !from.IsValid() || !to.IsValid() || from.BitLen() != to.BitLen() {
+		return Range{}, fmt.Errorf("range %s-%s has different families\n", from, to)
+	}
+	if to.Less(from) {
+		return Range{}, fmt.Errorf("range %s-%s is reversed\n", from, to)
+	}
+	return Range{from, to}, nil
+}
+
+// Contains reports whether addr is in r.
+func (r Range) Contains(addr netip.Addr) bool {
+	return addr.BitLen() == r.From.BitLen() && r.From.Compare(addr) <= 0 && addr.Compare(r.To) <= 0
+}
+
+// String returns r as "From-To".
+func (r Range) String() string {
+	return r.From.String() + "-" + r.To.String()
+}
+
+// CIDRs returns the minimal list of CIDRs which cover exactly r.
+func (r Range) CIDRs() []CIDRInfo {
+	var cidrs []CIDRInfo
+	for _, p := range r.Prefixes() {
+		cidrs = append(cidrs, FromPrefix(p))
+	}
+	return cidrs
+}
+
+// Prefixes is the same as CIDRs but returns netip.Prefix values.
+func (r Range) Prefixes() []netip.Prefix {
+	var prefixes []netip.Prefix
+	is4 := r.From.Is4()
+	bitLen := r.From.BitLen()
+	cur, to := u128FromAddr(r.From), u128FromAddr(r.To)
+	for {
+		hostBits := cur.trailingZeros()
+		if hostBits > bitLen {
+			hostBits = bitLen
+		}
+		for hostBits > 0 && cur.or(hostMask(hostBits)).cmp(to) > 0 {
+			hostBits--
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(cur.addr(is4), bitLen-hostBits))
+
+		last := cur.or(hostMask(hostBits))
+		if last.cmp(to) >= 0 {
+			break
+		}
+		cur = last.addOne()
+	}
+
+	return prefixes
+}
+
+// lastAddr returns the last address of p.
+func lastAddr(p netip.Prefix) netip.Addr {
+	a := p.Addr()
+	return u128FromAddr(a).or(hostMask(a.BitLen() - p.Bits())).addr(a.Is4())
+}
+
+// AddrCount returns the number of addresses in r.
+func (r Range) AddrCount() *big.Int {
+	n := u128FromAddr(r.To).big()
+	n.Sub(n, u128FromAddr(r.From).big())
+	return n.Add(n, big.NewInt(1))
+}
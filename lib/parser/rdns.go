@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+const (
+	ARPA_IPV4 = "in-addr.arpa"
+	ARPA_IPV6 = "ip6.arpa"
+)
+
+// ReverseZones returns the reverse DNS zones which cover the network.
+// Zones are at octet boundaries in in-addr.arpa for IPv4 and at nibble
+// boundaries in ip6.arpa for IPv6, so a /22 has four /24 zones. A network
+// longer than /24 for IPv4 or /124 for IPv6 returns the zone which contains
+// it, see ClasslessZone for RFC 2317 delegation.
+func (cidr CIDRInfo) ReverseZones() []string {
+	prefixes := cidr.ReverseZonePrefixes()
+	zones := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		zones[i] = ReverseZoneName(p)
+	}
+	return zones
+}
+
+// ReverseZonePrefixes is ReverseZones which returns the networks of the
+// zones instead of the names.
+func (cidr CIDRInfo) ReverseZonePrefixes() []netip.Prefix {
+	if !cidr.IsValid() {
+		return nil
+	}
+
+	a := cidr.prefix.Addr()
+	unit := 4
+	if a.Is4() {
+		unit = 8
+	}
+
+	boundary := (cidr.ones + unit - 1) / unit * unit
+	if max := a.BitLen() - unit; boundary > max {
+		boundary = max
+	}
+
+	count := 1
+	if boundary > cidr.ones {
+		count = 1 << uint(boundary-cidr.ones)
+	}
+	cur := u128FromAddr(a).and(hostMask(a.BitLen() - boundary).not())
+	step := hostMask(a.BitLen() - boundary).addOne()
+
+	prefixes := make([]netip.Prefix, 0, count)
+	for i := 0; i < count; i++ {
+		prefixes = append(prefixes, netip.PrefixFrom(cur.addr(a.Is4()), boundary))
+		cur = cur.add(step)
+	}
+
+	return prefixes
+}
+
+// ReverseZoneName returns the reverse DNS zone name of p, whose length
+// must be a multiple of 8 for IPv4 and of 4 for IPv6.
+func ReverseZoneName(p netip.Prefix) string {
+	return reverseName(p.Addr(), p.Bits())
+}
+
+// ClasslessZone returns the RFC 2317 classless delegation zone name such as
+// "64/26.1.168.192.in-addr.arpa" of an IPv4 network from /25 to /31, or ""
+// for other networks.
+func (cidr CIDRInfo) ClasslessZone() string {
+	if cidr.t != TYPE_IPV4 || cidr.ones <= 24 || cidr.ones >= 32 {
+		return ""
+	}
+
+	a := cidr.prefix.Addr()
+	return fmt.Sprintf("%d/%d.%s", a.As4()[3], cidr.ones, reverseName(a, 24))
+}
+
+// ReverseName returns the PTR record name of addr such as
+// "1.1.168.192.in-addr.arpa".
+func ReverseName(addr netip.Addr) string {
+	addr = addr.Unmap()
+	return reverseName(addr, addr.BitLen())
+}
+
+// reverseName returns the reverse DNS name of the first n bits of addr. n is
+// a multiple of 8 for IPv4 and of 4 for IPv6.
+func reverseName(addr netip.Addr, n int) string {
+	labels := ReverseLabels(addr, n)
+	if addr.Is4() {
+		labels = append(labels, ARPA_IPV4)
+	} else {
+		labels = append(labels, ARPA_IPV6)
+	}
+	return strings.Join(labels, ".")
+}
+
+// ReverseLabels returns the labels of the first n bits of addr in reverse
+// order, octets for IPv4 and nibbles for IPv6.
+func ReverseLabels(addr netip.Addr, n int) []string {
+	var labels []string
+	if addr.Is4() {
+		b := addr.As4()
+		for i := n/8 - 1; i >= 0; i-- {
+			labels = append(labels, strconv.Itoa(int(b[i])))
+		}
+		return labels
+	}
+
+	b := addr.As16()
+	for i := n/4 - 1; i >= 0; i-- {
+		nibble := b[i/2] >> 4
+		if i%2 == 1 {
+			nibble = b[i/2] & 0x0f
+		}
+		labels = append(labels, strconv.FormatUint(uint64(nibble), 16))
+	}
+	return labels
+}
@@ -0,0 +1,165 @@
+package reader
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// awsRanges is ip-ranges.json of AWS.
+type awsRanges struct {
+	Prefixes []struct {
+		IPPrefix           string `json:"ip_prefix"`
+		Region             string `json:"region"`
+		Service            string `json:"service"`
+		NetworkBorderGroup string `json:"network_border_group"`
+	} `json:"prefixes"`
+	IPv6Prefixes []struct {
+		IPv6Prefix         string `json:"ipv6_prefix"`
+		Region             string `json:"region"`
+		Service            string `json:"service"`
+		NetworkBorderGroup string `json:"network_border_group"`
+	} `json:"ipv6_prefixes"`
+}
+
+// gcpRanges is cloud.json of Google Cloud.
+type gcpRanges struct {
+	Prefixes []struct {
+		IPv4Prefix string `json:"ipv4Prefix"`
+		IPv6Prefix string `json:"ipv6Prefix"`
+		Service    string `json:"service"`
+		Scope      string `json:"scope"`
+	} `json:"prefixes"`
+}
+
+// azureTags is a service tags file of Azure.
+type azureTags struct {
+	Values []struct {
+		Name       string `json:"name"`
+		Properties struct {
+			Region          string   `json:"region"`
+			SystemService   string   `json:"systemService"`
+			AddressPrefixes []string `json:"addressPrefixes"`
+		} `json:"properties"`
+	} `json:"values"`
+}
+
+// cloudflareIPs is the response of the ips API of Cloudflare.
+type cloudflareIPs struct {
+	Result struct {
+		IPv4CIDRs []string `json:"ipv4_cidrs"`
+		IPv6CIDRs []string `json:"ipv6_cidrs"`
+	} `json:"result"`
+}
+
+// cloudSource returns the Source of a published prefix of provider. The
+// label is the provider, the service and the region, and they are also
+// attributes.
+func cloudSource(ln int, cidr string, provider string, service string, region string) Source {
+	var label []string
+	for _, s := range []string{provider, service, region} {
+		if s != "" {
+			label = append(label, s)
+		}
+	}
+
+	attrs := []parser.Attr{{Key: "provider", Value: provider}}
+	attrs = appendAttr(attrs, "service", service)
+	attrs = appendAttr(attrs, "region", region)
+	return Source{Line: ln, CIDR: cidr, Label: strings.Join(label, " "), Attrs: attrs}
+}
+
+// readAWS reads ip-ranges.json of AWS. The network border group is an
+// attribute.
+func readAWS(r io.Reader) ([]Source, error) {
+	var ranges awsRanges
+	if err := json.NewDecoder(r).Decode(&ranges); err != nil {
+		return nil, err
+	}
+
+	var srcs []Source
+	for _, p := range ranges.Prefixes {
+		src := cloudSource(len(srcs)+1, p.IPPrefix, "aws", p.Service, p.Region)
+		src.Attrs = appendAttr(src.Attrs, "network_border_group", p.NetworkBorderGroup)
+		srcs = append(srcs, src)
+	}
+	for _, p := range ranges.IPv6Prefixes {
+		src := cloudSource(len(srcs)+1, p.IPv6Prefix, "aws", p.Service, p.Region)
+		src.Attrs = appendAttr(src.Attrs, "network_border_group", p.NetworkBorderGroup)
+		srcs = append(srcs, src)
+	}
+
+	return srcs, nil
+}
+
+// readGCP reads cloud.json of Google Cloud, whose scope is the region.
+func readGCP(r io.Reader) ([]Source, error) {
+	var ranges gcpRanges
+	if err := json.NewDecoder(r).Decode(&ranges); err != nil {
+		return nil, err
+	}
+
+	var srcs []Source
+	for _, p := range ranges.Prefixes {
+		cidr := p.IPv4Prefix
+		if cidr == "" {
+			cidr = p.IPv6Prefix
+		}
+		srcs = append(srcs, cloudSource(len(srcs)+1, cidr, "gcp", p.Service, p.Scope))
+	}
+
+	return srcs, nil
+}
+
+// readAzure reads a service tags file of Azure. The name of the service tag
+// such as "AzureCloud.eastus" is the service, or the system service if it
+// is set.
+func readAzure(r io.Reader) ([]Source, error) {
+	var tags azureTags
+	if err := json.NewDecoder(r).Decode(&tags); err != nil {
+		return nil, err
+	}
+
+	var srcs []Source
+	for _, v := range tags.Values {
+		service := v.Properties.SystemService
+		if service == "" {
+			service = v.Name
+		}
+		for _, p := range v.Properties.AddressPrefixes {
+			src := cloudSource(len(srcs)+1, p, "azure", service, v.Properties.Region)
+			src.Attrs = appendAttr(src.Attrs, "tag", v.Name)
+			srcs = append(srcs, src)
+		}
+	}
+
+	return srcs, nil
+}
+
+// readCloudflare reads the ips-v4 and ips-v6 lists of Cloudflare, which are
+// a CIDR in each line, or the json of its ips API.
+func readCloudflare(r io.Reader) ([]Source, error) {
+	br := bufio.NewReader(r)
+	if peekNonSpace(br) != '{' {
+		srcs, err := readText(br)
+		for i := range srcs {
+			srcs[i] = cloudSource(srcs[i].Line, srcs[i].CIDR, "cloudflare", "", "")
+		}
+		return srcs, err
+	}
+
+	var ips cloudflareIPs
+	if err := json.NewDecoder(br).Decode(&ips); err != nil {
+		return nil, err
+	}
+
+	var srcs []Source
+	for _, cidr := range append(ips.Result.IPv4CIDRs, ips.Result.IPv6CIDRs...) {
+		srcs = append(srcs, cloudSource(len(srcs)+1, cidr, "cloudflare", "", ""))
+	}
+
+	return srcs, nil
+}
@@ -0,0 +1,126 @@
+package reader
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// candidates of addresses with an optional "/bits" or "/mask" suffix
+var grepToken = regexp.MustCompile(`[0-9A-Fa-f:.]+(?:/[0-9.]+)?`)
+
+// readGrep extracts IPv4 and IPv6 CIDRs, address and mask pairs such as
+// "10.0.0.0 255.255.255.0" or "10.0.0.0/255.255.255.0" and bare addresses
+// from free text. Bare addresses are host CIDRs. Only the first CIDR of each
+// network is kept and its line number is set to the "line" attribute.
+func readGrep(r io.Reader) ([]Source, error) {
+	var srcs []Source
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for ln := 1; scanner.Scan(); ln++ {
+		line := scanner.Text()
+		locs := grepToken.FindAllStringIndex(line, -1)
+		for i := 0; i < len(locs); i++ {
+			tok := line[locs[i][0]:locs[i][1]]
+
+			// the next token is a mask of a bare address
+			next := ""
+			if i+1 < len(locs) && strings.TrimSpace(line[locs[i][1]:locs[i+1][0]]) == "" {
+				next = line[locs[i+1][0]:locs[i+1][1]]
+			}
+
+			p, usedNext, ok := extractPrefix(tok, next)
+			if !ok {
+				continue
+			}
+			if usedNext {
+				i++
+			}
+
+			network := p.Masked().String()
+			if seen[network] {
+				continue
+			}
+			seen[network] = true
+			srcs = append(srcs, Source{
+				Line:  ln,
+				CIDR:  p.String(),
+				Attrs: []parser.Attr{{Key: "line", Value: strconv.Itoa(ln)}},
+			})
+		}
+	}
+
+	return srcs, scanner.Err()
+}
+
+// extractPrefix returns the prefix of tok, and whether next is used as its
+// mask. Only a dotted netmask is taken from next, so a count such as
+// "10.0.0.1 5 times" is not a prefix length. "::" without a prefix length is
+// not an address but a part of text such as a time.
+func extractPrefix(tok string, next string) (netip.Prefix, bool, bool) {
+	src, suffix, hasSuffix := strings.Cut(tok, "/")
+	addr, ok := parseGrepAddr(src)
+	if !ok {
+		return netip.Prefix{}, false, false
+	}
+
+	if hasSuffix {
+		if bits, ok := parseGrepBits(addr, suffix); ok {
+			return netip.PrefixFrom(addr, bits), false, true
+		}
+	}
+	if addr.Is6() && addr.IsUnspecified() {
+		return netip.Prefix{}, false, false
+	}
+	if hasSuffix {
+		return netip.PrefixFrom(addr, addr.BitLen()), false, true
+	}
+
+	if addr.Is4() && next != "" {
+		if bits, ok := parseGrepMask(strings.TrimRight(next, ".")); ok && (bits > 0 || addr.IsUnspecified()) {
+			return netip.PrefixFrom(addr, bits), true, true
+		}
+	}
+
+	return netip.PrefixFrom(addr, addr.BitLen()), false, true
+}
+
+// parseGrepAddr parses src trimming trailing punctuation such as "." at the
+// end of a sentence.
+func parseGrepAddr(src string) (netip.Addr, bool) {
+	for _, s := range []string{src, strings.TrimRight(src, "."), strings.TrimRight(src, ".:")} {
+		if addr, err := netip.ParseAddr(s); err == nil && addr.Zone() == "" {
+			return addr.Unmap(), true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// parseGrepBits parses a prefix length or a dotted IPv4 netmask.
+func parseGrepBits(addr netip.Addr, s string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, n >= 0 && n <= addr.BitLen()
+	}
+	if !addr.Is4() {
+		return 0, false
+	}
+	return parseGrepMask(s)
+}
+
+// parseGrepMask parses a dotted IPv4 netmask.
+func parseGrepMask(s string) (int, bool) {
+	m, err := netip.ParseAddr(s)
+	if err != nil || !m.Is4() {
+		return 0, false
+	}
+	b := m.As4()
+	ones, bits := net.IPMask(b[:]).Size()
+	return ones, bits != 0
+}
@@ -0,0 +1,352 @@
+/*
+Package reader reads CIDR lists in several input formats.
+
+Every format returns Sources, which are CIDR strings with optional labels
+and attributes. Source.Parse turns them into parser.CIDRInfo values.
+*/
+package reader
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// Input formats.
+const (
+	FORMAT_TEXT = "text"
+	FORMAT_CSV  = "csv"
+	FORMAT_TSV  = "tsv"
+	FORMAT_JSON = "json"
+	FORMAT_GREP = "grep"
+
+	FORMAT_IP_ROUTE        = "ip-route"        // ip -j route
+	FORMAT_IP_ADDR         = "ip-addr"         // ip -j addr
+	FORMAT_PROC_ROUTE      = "proc-route"      // /proc/net/route
+	FORMAT_PROC_IPV6_ROUTE = "proc-ipv6-route" // /proc/net/ipv6_route
+
+	FORMAT_AWS        = "aws"        // ip-ranges.json
+	FORMAT_GCP        = "gcp"        // cloud.json
+	FORMAT_AZURE      = "azure"      // ServiceTags_*.json
+	FORMAT_CLOUDFLARE = "cloudflare" // ips-v4, ips-v6 or the ips API
+)
+
+// columns which are tried in order when no column is selected
+var defaultColumns = []string{"source_cidr", "cidr", "prefix"}
+
+// Source is an unparsed CIDR with its label and attributes.
+type Source struct {
+	Line  int // line number for text, csv and tsv, record number for json
+	CIDR  string
+	Label string
+	Attrs []parser.Attr
+}
+
+// Parse parses the CIDR and sets the label and the attributes.
+func (s Source) Parse() (parser.CIDRInfo, error) {
+	cidr, err := parser.Parse(s.CIDR)
+	if err != nil {
+		return cidr, err
+	}
+	cidr.Label = s.Label
+	cidr.Attrs = s.Attrs
+	return cidr, nil
+}
+
+// TextSource returns the Source of a line of a labelled CIDR list.
+// See parser.ParseLine.
+func TextSource(ln int, line string) Source {
+	src, label, attrs := parser.SplitLine(line)
+	return Source{Line: ln, CIDR: src, Label: label, Attrs: attrs}
+}
+
+// Read reads Sources from r in format.
+//
+// For csv and tsv the first row is the header and column names the column
+// of the CIDR. For json, which is an array or newline delimited objects,
+// column names the field of the CIDR. When column is empty "source_cidr",
+// "cidr" and "prefix" are tried, and then the first csv or tsv column is
+// used. So the csv and tsv output of ipcl can be read again.
+//
+// A "label" column is the label and an "attributes" column holds key=value
+// attributes. Columns calculated by ipcl such as "network" are dropped and
+// other columns are passed through as attributes.
+//
+// grep extracts CIDRs and addresses from free text, see readGrep. The
+// routing table and interface address formats label each prefix with its
+// gateway and interface, see readIPRoute. The published IP range files of
+// cloud providers label each prefix with the provider, the service and the
+// region, see cloudSource.
+func Read(r io.Reader, format string, column string) ([]Source, error) {
+	switch format {
+	case FORMAT_TEXT, "":
+		return readText(r)
+	case FORMAT_CSV:
+		return readSep(r, ',', column)
+	case FORMAT_TSV:
+		return readSep(r, '\t', column)
+	case FORMAT_JSON:
+		return readJSON(r, column)
+	case FORMAT_GREP:
+		return readGrep(r)
+	case FORMAT_IP_ROUTE:
+		return readIPRoute(r)
+	case FORMAT_IP_ADDR:
+		return readIPAddr(r)
+	case FORMAT_PROC_ROUTE:
+		return readProcRoute(r)
+	case FORMAT_PROC_IPV6_ROUTE:
+		return readProcIPv6Route(r)
+	case FORMAT_AWS:
+		return readAWS(r)
+	case FORMAT_GCP:
+		return readGCP(r)
+	case FORMAT_AZURE:
+		return readAzure(r)
+	case FORMAT_CLOUDFLARE:
+		return readCloudflare(r)
+	}
+	return nil, fmt.Errorf("input format %s is not supported\n", format)
+}
+
+// Filter returns the Sources whose attribute key is one of values, compared
+// case insensitively. All Sources are returned if values is empty.
+func Filter(srcs []Source, key string, values []string) []Source {
+	if len(values) == 0 {
+		return srcs
+	}
+
+	var res []Source
+	for _, s := range srcs {
+		for _, a := range s.Attrs {
+			if a.Key == key && containsFold(values, a.Value) {
+				res = append(res, s)
+				break
+			}
+		}
+	}
+	return res
+}
+
+func containsFold(values []string, v string) bool {
+	for _, s := range values {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func readText(r io.Reader) ([]Source, error) {
+	var srcs []Source
+
+	scanner := bufio.NewScanner(r)
+	for ln := 1; scanner.Scan(); ln++ {
+		// skip blank and comment lines
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		srcs = append(srcs, TextSource(ln, line))
+	}
+
+	return srcs, scanner.Err()
+}
+
+func readSep(r io.Reader, sep rune, column string) ([]Source, error) {
+	var srcs []Source
+
+	cr := csv.NewReader(r)
+	cr.Comma = sep
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = sep == '\t'
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return srcs, nil
+	} else if err != nil {
+		return srcs, err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	ci := -1
+	if column != "" {
+		ci = indexOf(header, column)
+		if ci < 0 {
+			return srcs, fmt.Errorf("column %s is not found in header\n", column)
+		}
+	} else {
+		for _, c := range defaultColumns {
+			if ci = indexOf(header, c); ci >= 0 {
+				break
+			}
+		}
+		if ci < 0 {
+			ci = 0
+		}
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return srcs, err
+		}
+		ln, _ := cr.FieldPos(0)
+
+		src := Source{Line: ln}
+		for i, name := range header {
+			value := ""
+			if i < len(record) {
+				value = strings.TrimSpace(record[i])
+			}
+			if i == ci {
+				src.CIDR = value
+				continue
+			}
+			src.set(name, value)
+		}
+		srcs = append(srcs, src)
+	}
+
+	return srcs, nil
+}
+
+func readJSON(r io.Reader, field string) ([]Source, error) {
+	var srcs []Source
+
+	br := bufio.NewReader(r)
+	dec := json.NewDecoder(br)
+	dec.UseNumber()
+
+	var records []interface{}
+	if isArray(br) {
+		if err := dec.Decode(&records); err != nil {
+			return srcs, err
+		}
+	} else {
+		for {
+			var rec interface{}
+			if err := dec.Decode(&rec); err == io.EOF {
+				break
+			} else if err != nil {
+				return srcs, err
+			}
+			records = append(records, rec)
+		}
+	}
+
+	for i, rec := range records {
+		src := Source{Line: i + 1}
+		switch v := rec.(type) {
+		case string:
+			src.CIDR = v
+		case map[string]interface{}:
+			f := field
+			if f == "" {
+				for _, c := range defaultColumns {
+					if _, ok := v[c]; ok {
+						f = c
+						break
+					}
+				}
+			}
+			cidr, ok := v[f].(string)
+			if !ok {
+				return srcs, fmt.Errorf("record %d: field %s is not found\n", i+1, f)
+			}
+			src.CIDR = cidr
+
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				if k != f {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if attrs, ok := v[k].(map[string]interface{}); ok && k == "attributes" {
+					// attributes written by the json output of ipcl
+					names := make([]string, 0, len(attrs))
+					for n := range attrs {
+						names = append(names, n)
+					}
+					sort.Strings(names)
+					for _, n := range names {
+						src.Attrs = append(src.Attrs, parser.Attr{Key: n, Value: jsonString(attrs[n])})
+					}
+					continue
+				}
+				src.set(k, jsonString(v[k]))
+			}
+		default:
+			return srcs, fmt.Errorf("record %d: %v is not an object or a string\n", i+1, rec)
+		}
+		srcs = append(srcs, src)
+	}
+
+	return srcs, nil
+}
+
+// set sets a passed through column to the label or the attributes.
+func (s *Source) set(name string, value string) {
+	switch {
+	case name == "label":
+		s.Label = value
+	case name == "attributes":
+		s.Attrs = append(s.Attrs, parser.ParseAttrsString(value)...)
+	case indexOf(parser.Columns(), name) >= 0:
+		// calculated again by parser
+	default:
+		s.Attrs = append(s.Attrs, parser.Attr{Key: name, Value: value})
+	}
+}
+
+// isArray reports whether the next non space byte of br is "[".
+func isArray(br *bufio.Reader) bool {
+	return peekNonSpace(br) == '['
+}
+
+// peekNonSpace returns the next non space byte of br without reading it, or
+// 0 at the end.
+func peekNonSpace(br *bufio.Reader) byte {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b)) {
+			br.UnreadByte()
+			return b
+		}
+	}
+}
+
+func jsonString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case nil:
+		return ""
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func indexOf(s []string, v string) int {
+	for i := range s {
+		if s[i] == v {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,331 @@
+package reader
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+	"github.com/goldeneggg/ipcl/lib/writer"
+)
+
+var readTests = []struct {
+	format   string
+	column   string
+	src      string
+	expected []Source
+}{
+	{FORMAT_TEXT, "", "# comment\n\n10.0.0.0/8 corp owner=netops\n 192.168.1.0/24\n", []Source{
+		{Line: 3, CIDR: "10.0.0.0/8", Label: "corp", Attrs: []parser.Attr{{Key: "owner", Value: "netops"}}},
+		{Line: 4, CIDR: "192.168.1.0/24"}}},
+	{FORMAT_CSV, "prefix", "name,prefix,site\nprod,10.0.0.0/8,tokyo\ndev,\"192.168.1.0/24\",\n", []Source{
+		{Line: 2, CIDR: "10.0.0.0/8", Attrs: []parser.Attr{{Key: "name", Value: "prod"}, {Key: "site", Value: "tokyo"}}},
+		{Line: 3, CIDR: "192.168.1.0/24", Attrs: []parser.Attr{{Key: "name", Value: "dev"}, {Key: "site", Value: ""}}}}},
+	{FORMAT_CSV, "", "source_cidr,network,mask,host_num,min_address,max_address,broadcast,reverse_zone,label,attributes\n" +
+		"10.20.0.0/16,10.20.0.0,255.255.0.0,65534,10.20.0.1,10.20.255.254,10.20.255.255,20.10.in-addr.arpa,prod-vpc,owner=netops env=prod\n", []Source{
+		{Line: 2, CIDR: "10.20.0.0/16", Label: "prod-vpc", Attrs: []parser.Attr{{Key: "owner", Value: "netops"}, {Key: "env", Value: "prod"}}}}},
+	{FORMAT_TSV, "", "net\tlabel\n10.0.0.0/8\tcorp\n", []Source{
+		{Line: 2, CIDR: "10.0.0.0/8", Label: "corp"}}},
+	{FORMAT_JSON, "", `[{"cidr": "10.0.0.0/8", "vlan": 10, "tags": ["a"]}, "192.168.1.0/24"]`, []Source{
+		{Line: 1, CIDR: "10.0.0.0/8", Attrs: []parser.Attr{{Key: "tags", Value: `["a"]`}, {Key: "vlan", Value: "10"}}},
+		{Line: 2, CIDR: "192.168.1.0/24"}}},
+	{FORMAT_JSON, "net", "{\"net\": \"10.0.0.0/8\", \"label\": \"corp\"}\n{\"net\": \"2001:db8::/32\"}\n", []Source{
+		{Line: 1, CIDR: "10.0.0.0/8", Label: "corp"},
+		{Line: 2, CIDR: "2001:db8::/32"}}},
+}
+
+func TestRead(t *testing.T) {
+	for _, rt := range readTests {
+		actual, e := Read(strings.NewReader(rt.src), rt.format, rt.column)
+		if e != nil {
+			t.Errorf("Read(%s, %q) error: %#v", rt.format, rt.src, e)
+			continue
+		}
+		if !reflect.DeepEqual(actual, rt.expected) {
+			t.Errorf("Read(%s, %q) actual: %+v, expected: %+v", rt.format, rt.src, actual, rt.expected)
+		}
+	}
+}
+
+func TestReadError(t *testing.T) {
+	for _, rt := range []struct {
+		format string
+		column string
+		src    string
+	}{
+		{"xml", "", "<cidr/>"},
+		{FORMAT_CSV, "prefix", "cidr\n10.0.0.0/8\n"},
+		{FORMAT_JSON, "prefix", `[{"cidr": "10.0.0.0/8"}]`},
+		{FORMAT_JSON, "", `[1]`},
+		{FORMAT_JSON, "", `{"cidr": `},
+	} {
+		if _, e := Read(strings.NewReader(rt.src), rt.format, rt.column); e == nil {
+			t.Errorf("Read(%s, %q) expected error", rt.format, rt.src)
+		}
+	}
+}
+
+func TestSourceParse(t *testing.T) {
+	ci, e := TextSource(1, "10.0.0.0/8 corp owner=netops").Parse()
+	if e != nil || ci.HostNum != 16777214 || ci.Label != "corp" || ci.AttrsString() != "owner=netops" {
+		t.Errorf("Parse actual: %+v %v", ci, e)
+	}
+	if _, e := (Source{CIDR: "10.0.0.0/33"}).Parse(); e == nil {
+		t.Errorf("Parse invalid CIDR expected error")
+	}
+}
+
+func TestReadWriteRoundTrip(t *testing.T) {
+	ci, e := parser.Parse("10.20.0.0/16")
+	if e != nil {
+		t.Fatalf("Parse error: %#v", e)
+	}
+	ci.Label = `prod, "main" vpc`
+	ci.Attrs = []parser.Attr{{Key: "owner", Value: "net ops"}, {Key: "note", Value: `say "hi"`}, {Key: "env", Value: "prod"}}
+	expected := []Source{{Line: 2, CIDR: ci.SrcCIDR, Label: ci.Label, Attrs: ci.Attrs}}
+
+	out := writer.Out
+	defer func() { writer.Out = out }()
+
+	for _, format := range []string{FORMAT_CSV, FORMAT_TSV} {
+		var buf bytes.Buffer
+		writer.Out = &buf
+		w, e := writer.NewFormatWriter(format, writer.Options{})
+		if e != nil {
+			t.Fatalf("NewFormatWriter(%s) error: %#v", format, e)
+		}
+		if e := writer.WriteAll(w, []parser.CIDRInfo{ci}); e != nil {
+			t.Fatalf("Write %s error: %#v", format, e)
+		}
+
+		actual, e := Read(&buf, format, "")
+		if e != nil || !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Read(%s) of written %s actual: %+v %v, expected: %+v", format, format, actual, e, expected)
+		}
+	}
+}
+
+const grepText = `interface Gi0/1
+ ip address 10.1.2.1 255.255.255.0
+ ip route 0.0.0.0 0.0.0.0 10.1.2.254
+Please allow 192.168.10.0/24 and 2001:db8:1::/48, also 10.9.9.9.
+Duplicate 192.168.10.0/24 and 10.1.2.1/255.255.255.0 here
+ 2001:db8::1 talked to [fe80::1]:443 at 12:34:56 from 00:11:22:33:44:55
+ version 1.2.3 and 999.1.1.1 are not addresses
+ 10.7.7.7 5 times, then 10.1.2.99/24 again
+ std :: vector, but ::/0 is a route
+`
+
+func TestReadGrep(t *testing.T) {
+	srcs, e := Read(strings.NewReader(grepText), FORMAT_GREP, "")
+	if e != nil {
+		t.Fatalf("Read grep error: %#v", e)
+	}
+
+	var actual []string
+	for _, s := range srcs {
+		actual = append(actual, strconv.Itoa(s.Line)+" "+s.CIDR+" "+s.Attrs[0].String())
+	}
+	expected := []string{
+		"2 10.1.2.1/24 line=2",
+		"3 0.0.0.0/0 line=3",
+		"3 10.1.2.254/32 line=3",
+		"4 192.168.10.0/24 line=4",
+		"4 2001:db8:1::/48 line=4",
+		"4 10.9.9.9/32 line=4",
+		"6 2001:db8::1/128 line=6",
+		"6 fe80::1/128 line=6",
+		"8 10.7.7.7/32 line=8",
+		"9 ::/0 line=9",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Read grep actual:\n%s\nexpected:\n%s", strings.Join(actual, "\n"), strings.Join(expected, "\n"))
+	}
+
+	for _, s := range srcs {
+		if _, e := s.Parse(); e != nil {
+			t.Errorf("Parse(%s) error: %#v", s.CIDR, e)
+		}
+	}
+}
+
+func TestReadJSONOutput(t *testing.T) {
+	src := `[
+  {"source_cidr":"10.20.0.0/16","network":"10.20.0.0","mask":"255.255.0.0","host_num":65534,"min_address":"10.20.0.1","max_address":"10.20.255.254","broadcast":"10.20.255.255","reverse_zone":["20.10.in-addr.arpa"],"label":"prod-vpc","attributes":{"owner":"netops","env":"prod"}}
+]`
+	actual, e := Read(strings.NewReader(src), FORMAT_JSON, "")
+	expected := []Source{{Line: 1, CIDR: "10.20.0.0/16", Label: "prod-vpc", Attrs: []parser.Attr{{Key: "env", Value: "prod"}, {Key: "owner", Value: "netops"}}}}
+	if e != nil || !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Read json output actual: %+v %v, expected: %+v", actual, e, expected)
+	}
+}
+
+var routeTests = []struct {
+	format   string
+	src      string
+	expected []string
+}{
+	{FORMAT_IP_ROUTE, `[{"dst":"default","gateway":"192.168.1.1","dev":"eth0","protocol":"dhcp","prefsrc":"192.168.1.10","metric":100,"flags":[]},
+{"dst":"10.3.0.0/16","gateway":"10.0.0.254","dev":"tun0","flags":[]},
+{"dst":"192.168.1.0/24","dev":"eth0","protocol":"kernel","scope":"link","prefsrc":"192.168.1.10","flags":[]},
+{"type":"local","dst":"192.168.1.10","table":"local","dev":"eth0","protocol":"kernel","scope":"host","flags":[]},
+{"dst":"10.8.0.0/16","flags":[],"nexthops":[{"gateway":"10.0.0.1","dev":"eth1","weight":1,"flags":[]},{"gateway":"10.0.0.2","dev":"eth2","weight":1,"flags":[]}]},
+{"dst":"default","gateway":"fe80::1","dev":"eth0","protocol":"ra","metric":1024,"flags":[]}]`, []string{
+		"1 0.0.0.0/0 [via 192.168.1.1 dev eth0] proto=dhcp src=192.168.1.10 metric=100",
+		"2 10.3.0.0/16 [via 10.0.0.254 dev tun0]",
+		"3 192.168.1.0/24 [dev eth0] proto=kernel scope=link src=192.168.1.10",
+		"4 192.168.1.10/32 [dev eth0] type=local proto=kernel scope=host table=local",
+		"5 10.8.0.0/16 [via 10.0.0.1 dev eth1]",
+		"6 ::/0 [via fe80::1 dev eth0] proto=ra metric=1024",
+	}},
+	{FORMAT_IP_ADDR, `[{"ifindex":1,"ifname":"lo","addr_info":[{"family":"inet","local":"127.0.0.1","prefixlen":8,"scope":"host","label":"lo"},{"family":"inet6","local":"::1","prefixlen":128,"scope":"host"}]},
+{"ifindex":2,"ifname":"eth0","addr_info":[{"family":"inet","local":"192.168.1.10","prefixlen":24,"broadcast":"192.168.1.255","scope":"global"}]},
+{"ifindex":3,"ifname":"eth1","addr_info":[]}]`, []string{
+		"1 127.0.0.1/8 [dev lo] scope=host",
+		"2 ::1/128 [dev lo] scope=host",
+		"3 192.168.1.10/24 [dev eth0] scope=global",
+	}},
+	{FORMAT_PROC_ROUTE, `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	00000000	0101A8C0	0003	0	0	100	00000000	0	0	0
+eth0	0001A8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+tun0	0000030A	FE00000A	0003	0	0	0	0000FFFF	0	0	0
+`, []string{
+		"2 0.0.0.0/0 [via 192.168.1.1 dev eth0] metric=100",
+		"3 192.168.1.0/24 [dev eth0] metric=100",
+		"4 10.3.0.0/16 [via 10.0.0.254 dev tun0] metric=0",
+	}},
+	{FORMAT_PROC_IPV6_ROUTE, `20010db8000000000000000000000000 20 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00450003     eth0
+`, []string{
+		"1 2001:db8::/32 [dev eth0] metric=256",
+		"2 ::/0 [via fe80::1 dev eth0] metric=1024",
+	}},
+}
+
+func TestReadRoute(t *testing.T) {
+	for _, rt := range routeTests {
+		srcs, e := Read(strings.NewReader(rt.src), rt.format, "")
+		if e != nil {
+			t.Errorf("Read(%s) error: %#v", rt.format, e)
+			continue
+		}
+
+		var actual []string
+		for _, s := range srcs {
+			c, e := s.Parse()
+			if e != nil {
+				t.Errorf("Read(%s) Parse(%s) error: %#v", rt.format, s.CIDR, e)
+				continue
+			}
+			a := strconv.Itoa(s.Line) + " " + c.SrcCIDR + " [" + c.Label + "]"
+			if attrs := c.AttrsString(); attrs != "" {
+				a += " " + attrs
+			}
+			actual = append(actual, a)
+		}
+		if !reflect.DeepEqual(actual, rt.expected) {
+			t.Errorf("Read(%s) actual:\n%s\nexpected:\n%s", rt.format, strings.Join(actual, "\n"), strings.Join(rt.expected, "\n"))
+		}
+	}
+
+	for _, rt := range []struct {
+		format string
+		src    string
+	}{
+		{FORMAT_IP_ROUTE, `{"dst":"default"}`},
+		{FORMAT_PROC_ROUTE, "eth0\t00000000\t0101A8C0\n"},
+		{FORMAT_PROC_ROUTE, "eth0\t0001A8C0\t00000000\t0001\t0\t0\t100\t00FF00FF\n"},
+		{FORMAT_PROC_IPV6_ROUTE, "2001 20 0 00 0 0 0 0 0 eth0\n"},
+	} {
+		if _, e := Read(strings.NewReader(rt.src), rt.format, ""); e == nil {
+			t.Errorf("Read(%s, %q) expected error", rt.format, rt.src)
+		}
+	}
+}
+
+var cloudTests = []struct {
+	format   string
+	src      string
+	expected []string
+}{
+	{FORMAT_AWS, `{"syncToken":"1","createDate":"2024-01-01-00-00-00",
+"prefixes":[{"ip_prefix":"3.5.140.0/22","region":"ap-northeast-2","service":"AMAZON","network_border_group":"ap-northeast-2"},
+{"ip_prefix":"52.95.245.0/24","region":"us-east-1","service":"S3","network_border_group":"us-east-1"}],
+"ipv6_prefixes":[{"ipv6_prefix":"2600:1f14::/35","region":"us-west-2","service":"EC2","network_border_group":"us-west-2"}]}`, []string{
+		"1 3.5.140.0/22 [aws AMAZON ap-northeast-2] provider=aws service=AMAZON region=ap-northeast-2 network_border_group=ap-northeast-2",
+		"2 52.95.245.0/24 [aws S3 us-east-1] provider=aws service=S3 region=us-east-1 network_border_group=us-east-1",
+		"3 2600:1f14::/35 [aws EC2 us-west-2] provider=aws service=EC2 region=us-west-2 network_border_group=us-west-2",
+	}},
+	{FORMAT_GCP, `{"syncToken":"1","creationTime":"2024-01-01T00:00:00",
+"prefixes":[{"ipv4Prefix":"34.35.0.0/16","service":"Google Cloud","scope":"africa-south1"},
+{"ipv6Prefix":"2600:1900:8000::/44","service":"Google Cloud","scope":"africa-south1"}]}`, []string{
+		"1 34.35.0.0/16 [gcp Google Cloud africa-south1] provider=gcp service=Google Cloud region=africa-south1",
+		"2 2600:1900:8000::/44 [gcp Google Cloud africa-south1] provider=gcp service=Google Cloud region=africa-south1",
+	}},
+	{FORMAT_AZURE, `{"changeNumber":1,"cloud":"Public","values":[
+{"name":"ActionGroup","id":"ActionGroup","properties":{"region":"","platform":"Azure","systemService":"ActionGroup","addressPrefixes":["13.66.60.119/32","2603:1000:4:402::178/125"]}},
+{"name":"AzureCloud.eastus","id":"AzureCloud.eastus","properties":{"region":"eastus","platform":"Azure","systemService":"","addressPrefixes":["20.42.0.0/17"]}}]}`, []string{
+		"1 13.66.60.119/32 [azure ActionGroup] provider=azure service=ActionGroup tag=ActionGroup",
+		"2 2603:1000:4:402::178/125 [azure ActionGroup] provider=azure service=ActionGroup tag=ActionGroup",
+		"3 20.42.0.0/17 [azure AzureCloud.eastus eastus] provider=azure service=AzureCloud.eastus region=eastus tag=AzureCloud.eastus",
+	}},
+	{FORMAT_CLOUDFLARE, "173.245.48.0/20\n103.21.244.0/22\n", []string{
+		"1 173.245.48.0/20 [cloudflare] provider=cloudflare",
+		"2 103.21.244.0/22 [cloudflare] provider=cloudflare",
+	}},
+	{FORMAT_CLOUDFLARE, `{"result":{"ipv4_cidrs":["173.245.48.0/20"],"ipv6_cidrs":["2400:cb00::/32"],"etag":"x"},"success":true,"errors":[],"messages":[]}`, []string{
+		"1 173.245.48.0/20 [cloudflare] provider=cloudflare",
+		"2 2400:cb00::/32 [cloudflare] provider=cloudflare",
+	}},
+}
+
+func TestReadCloud(t *testing.T) {
+	for _, ct := range cloudTests {
+		srcs, e := Read(strings.NewReader(ct.src), ct.format, "")
+		if e != nil {
+			t.Errorf("Read(%s) error: %#v", ct.format, e)
+			continue
+		}
+
+		var actual []string
+		for _, s := range srcs {
+			if _, e := s.Parse(); e != nil {
+				t.Errorf("Read(%s) Parse(%s) error: %#v", ct.format, s.CIDR, e)
+			}
+			a := strconv.Itoa(s.Line) + " " + s.CIDR + " [" + s.Label + "]"
+			for _, attr := range s.Attrs {
+				a += " " + attr.String()
+			}
+			actual = append(actual, a)
+		}
+		if !reflect.DeepEqual(actual, ct.expected) {
+			t.Errorf("Read(%s) actual:\n%s\nexpected:\n%s", ct.format, strings.Join(actual, "\n"), strings.Join(ct.expected, "\n"))
+		}
+	}
+
+	for _, format := range []string{FORMAT_AWS, FORMAT_GCP, FORMAT_AZURE, FORMAT_CLOUDFLARE} {
+		if _, e := Read(strings.NewReader(`{"prefixes": `), format, ""); e == nil {
+			t.Errorf("Read(%s) of broken json expected error", format)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	srcs, _ := Read(strings.NewReader(cloudTests[0].src), FORMAT_AWS, "")
+
+	var actual []string
+	for _, s := range Filter(srcs, "region", []string{"US-EAST-1", "us-west-2"}) {
+		actual = append(actual, s.CIDR)
+	}
+	if expected := []string{"52.95.245.0/24", "2600:1f14::/35"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Filter region actual: %v, expected: %v", actual, expected)
+	}
+	if f := Filter(srcs, "service", []string{"EC2"}); len(f) != 1 || f[0].CIDR != "2600:1f14::/35" {
+		t.Errorf("Filter service actual: %+v", f)
+	}
+	if f := Filter(srcs, "service", nil); len(f) != len(srcs) {
+		t.Errorf("Filter without values actual: %+v", f)
+	}
+}
@@ -0,0 +1,257 @@
+package reader
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// flags of /proc/net/route and /proc/net/ipv6_route
+const rtfGateway = 0x2
+
+// ipRoute is a route of "ip -j route".
+type ipRoute struct {
+	Type     string      `json:"type"`
+	Dst      string      `json:"dst"`
+	Gateway  string      `json:"gateway"`
+	Dev      string      `json:"dev"`
+	Protocol string      `json:"protocol"`
+	Scope    string      `json:"scope"`
+	Prefsrc  string      `json:"prefsrc"`
+	Metric   json.Number `json:"metric"`
+	Table    string      `json:"table"`
+	Nexthops []struct {
+		Gateway string `json:"gateway"`
+		Dev     string `json:"dev"`
+	} `json:"nexthops"`
+}
+
+// ipLink is an interface of "ip -j addr".
+type ipLink struct {
+	Ifname   string `json:"ifname"`
+	AddrInfo []struct {
+		Local     string `json:"local"`
+		Prefixlen int    `json:"prefixlen"`
+		Scope     string `json:"scope"`
+	} `json:"addr_info"`
+}
+
+// routeLabel returns the label of a route such as "via 10.0.0.1 dev eth0"
+// in the syntax of "ip route".
+func routeLabel(gateway string, dev string) string {
+	var s []string
+	if gateway != "" {
+		s = append(s, "via", gateway)
+	}
+	if dev != "" {
+		s = append(s, "dev", dev)
+	}
+	return strings.Join(s, " ")
+}
+
+// appendAttr appends key=value to attrs if value is not empty.
+func appendAttr(attrs []parser.Attr, key string, value string) []parser.Attr {
+	if value == "" {
+		return attrs
+	}
+	return append(attrs, parser.Attr{Key: key, Value: value})
+}
+
+// readIPRoute reads the json of "ip -j route". A host route without prefix
+// length is a host CIDR and "default" is the default route of the family of
+// the gateway, IPv4 if unknown. The label is the gateway and the interface,
+// the first ones for a multipath route.
+func readIPRoute(r io.Reader) ([]Source, error) {
+	var routes []ipRoute
+	if err := json.NewDecoder(r).Decode(&routes); err != nil {
+		return nil, err
+	}
+
+	var srcs []Source
+	for i, rt := range routes {
+		gateway, dev := rt.Gateway, rt.Dev
+		if gateway == "" && dev == "" && len(rt.Nexthops) > 0 {
+			gateway, dev = rt.Nexthops[0].Gateway, rt.Nexthops[0].Dev
+		}
+
+		cidr := rt.Dst
+		switch {
+		case cidr == "default" && (strings.Contains(gateway, ":") || strings.Contains(rt.Prefsrc, ":")):
+			cidr = "::/0"
+		case cidr == "default":
+			cidr = "0.0.0.0/0"
+		case !strings.Contains(cidr, "/"):
+			cidr = hostCIDR(cidr)
+		}
+
+		var attrs []parser.Attr
+		if rt.Type != "" && rt.Type != "unicast" {
+			attrs = appendAttr(attrs, "type", rt.Type)
+		}
+		attrs = appendAttr(attrs, "proto", rt.Protocol)
+		attrs = appendAttr(attrs, "scope", rt.Scope)
+		attrs = appendAttr(attrs, "src", rt.Prefsrc)
+		attrs = appendAttr(attrs, "metric", rt.Metric.String())
+		attrs = appendAttr(attrs, "table", rt.Table)
+
+		srcs = append(srcs, Source{Line: i + 1, CIDR: cidr, Label: routeLabel(gateway, dev), Attrs: attrs})
+	}
+
+	return srcs, nil
+}
+
+// readIPAddr reads the json of "ip -j addr". Each address is a CIDR of the
+// address and its prefix length, labelled with the interface.
+func readIPAddr(r io.Reader) ([]Source, error) {
+	var links []ipLink
+	if err := json.NewDecoder(r).Decode(&links); err != nil {
+		return nil, err
+	}
+
+	var srcs []Source
+	for _, l := range links {
+		for _, a := range l.AddrInfo {
+			if a.Local == "" {
+				continue
+			}
+			srcs = append(srcs, Source{
+				Line:  len(srcs) + 1,
+				CIDR:  a.Local + "/" + strconv.Itoa(a.Prefixlen),
+				Label: routeLabel("", l.Ifname),
+				Attrs: appendAttr(nil, "scope", a.Scope),
+			})
+		}
+	}
+
+	return srcs, nil
+}
+
+// readProcRoute reads /proc/net/route, whose addresses are hexadecimal in
+// host byte order, which is assumed to be little endian.
+func readProcRoute(r io.Reader) ([]Source, error) {
+	var srcs []Source
+
+	scanner := bufio.NewScanner(r)
+	for ln := 1; scanner.Scan(); ln++ {
+		f := strings.Fields(scanner.Text())
+		if len(f) == 0 || f[0] == "Iface" {
+			continue
+		}
+		if len(f) < 8 {
+			return srcs, fmt.Errorf("line %d: %d fields are less than 8\n", ln, len(f))
+		}
+
+		dst, err1 := procAddr4(f[1])
+		gateway, err2 := procAddr4(f[2])
+		flags, err3 := strconv.ParseUint(f[3], 16, 32)
+		mask, err4 := procAddr4(f[7])
+		for _, err := range []error{err1, err2, err3, err4} {
+			if err != nil {
+				return srcs, fmt.Errorf("line %d: %s\n", ln, err)
+			}
+		}
+		m := mask.As4()
+		bits, size := net.IPMask(m[:]).Size()
+		if size == 0 {
+			return srcs, fmt.Errorf("line %d: mask %s is not contiguous\n", ln, mask)
+		}
+
+		gw := ""
+		if flags&rtfGateway != 0 {
+			gw = gateway.String()
+		}
+		srcs = append(srcs, Source{
+			Line:  ln,
+			CIDR:  netip.PrefixFrom(dst, bits).String(),
+			Label: routeLabel(gw, f[0]),
+			Attrs: appendAttr(nil, "metric", f[6]),
+		})
+	}
+
+	return srcs, scanner.Err()
+}
+
+// readProcIPv6Route reads /proc/net/ipv6_route, whose lines are the
+// destination, its prefix length, the source, its prefix length, the next
+// hop, the metric, the reference count, the use count, the flags and the
+// interface.
+func readProcIPv6Route(r io.Reader) ([]Source, error) {
+	var srcs []Source
+
+	scanner := bufio.NewScanner(r)
+	for ln := 1; scanner.Scan(); ln++ {
+		f := strings.Fields(scanner.Text())
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) < 10 {
+			return srcs, fmt.Errorf("line %d: %d fields are less than 10\n", ln, len(f))
+		}
+
+		dst, err1 := procAddr6(f[0])
+		bits, err2 := strconv.ParseUint(f[1], 16, 8)
+		nexthop, err3 := procAddr6(f[4])
+		metric, err4 := strconv.ParseUint(f[5], 16, 32)
+		flags, err5 := strconv.ParseUint(f[8], 16, 32)
+		for _, err := range []error{err1, err2, err3, err4, err5} {
+			if err != nil {
+				return srcs, fmt.Errorf("line %d: %s\n", ln, err)
+			}
+		}
+
+		gw := ""
+		if flags&rtfGateway != 0 {
+			gw = nexthop.String()
+		}
+		srcs = append(srcs, Source{
+			Line:  ln,
+			CIDR:  dst.String() + "/" + strconv.FormatUint(bits, 10),
+			Label: routeLabel(gw, f[9]),
+			Attrs: appendAttr(nil, "metric", strconv.FormatUint(metric, 10)),
+		})
+	}
+
+	return srcs, scanner.Err()
+}
+
+// procAddr4 parses an IPv4 address of /proc/net/route such as "0100000A"
+// for 10.0.0.1 on little endian hosts.
+func procAddr4(s string) (netip.Addr, error) {
+	n, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(n))
+	return netip.AddrFrom4(b), nil
+}
+
+// procAddr6 parses an IPv6 address of 32 hexadecimal digits.
+func procAddr6(s string) (netip.Addr, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 16 {
+		return netip.Addr{}, fmt.Errorf("%s is not an IPv6 address", s)
+	}
+	var a [16]byte
+	copy(a[:], b)
+	return netip.AddrFrom16(a), nil
+}
+
+// hostCIDR returns the host CIDR of addr, or addr as is if it is not an
+// address.
+func hostCIDR(addr string) string {
+	a, err := netip.ParseAddr(addr)
+	if err != nil {
+		return addr
+	}
+	return netip.PrefixFrom(a, a.BitLen()).String()
+}
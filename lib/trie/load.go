@@ -0,0 +1,44 @@
+package trie
+
+import (
+	"net/netip"
+	"strconv"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// FromCIDRs returns a Trie which maps each CIDR of cidrs to its label. The
+// label of a CIDR without one is the CIDR itself, such as "10.20.0.0/16".
+// Of the CIDRs of the same network, the one which has the lowest "metric"
+// attribute wins like a routing table, otherwise the last one.
+func FromCIDRs(cidrs []parser.CIDRInfo) *Trie[string] {
+	t := New[string]()
+	metrics := make(map[netip.Prefix]uint64)
+	for _, cidr := range cidrs {
+		p := cidr.Prefix().Masked()
+		if m, ok := metricOf(cidr); ok {
+			if prev, found := metrics[p]; found && prev <= m {
+				continue
+			}
+			metrics[p] = m
+		} else {
+			delete(metrics, p)
+		}
+
+		label := cidr.Label
+		if label == "" {
+			label = cidr.SrcCIDR
+		}
+		t.InsertCIDR(cidr, label)
+	}
+	return t
+}
+
+func metricOf(cidr parser.CIDRInfo) (uint64, bool) {
+	v, ok := cidr.Attr("metric")
+	if !ok {
+		return 0, false
+	}
+	m, err := strconv.ParseUint(v, 10, 64)
+	return m, err == nil
+}
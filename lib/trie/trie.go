@@ -0,0 +1,247 @@
+/*
+Package trie provides Trie, a path compressed binary radix tree of IPv4 and
+IPv6 prefixes for longest prefix match lookups.
+
+	t := trie.New[string]()
+	t.Insert(netip.MustParsePrefix("10.0.0.0/8"), "corp")
+	t.Insert(netip.MustParsePrefix("10.20.0.0/16"), "prod-vpc")
+
+	p, label, ok := t.Lookup(netip.MustParseAddr("10.20.3.4"))
+	// 10.20.0.0/16 prod-vpc true
+*/
+package trie
+
+import (
+	"math/bits"
+	"net/netip"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// Entry is a prefix and its value.
+type Entry[V any] struct {
+	Prefix netip.Prefix
+	Value  V
+}
+
+// Trie maps prefixes to values. The zero value is not usable, use New.
+type Trie[V any] struct {
+	root4 *node[V]
+	root6 *node[V]
+	size  int
+}
+
+type node[V any] struct {
+	prefix   netip.Prefix
+	hasValue bool
+	value    V
+	child    [2]*node[V]
+}
+
+// New returns an empty Trie.
+func New[V any]() *Trie[V] {
+	return &Trie[V]{}
+}
+
+// Len returns the number of prefixes in t.
+func (t *Trie[V]) Len() int {
+	return t.size
+}
+
+func (t *Trie[V]) root(a netip.Addr) **node[V] {
+	if a.Is4() {
+		return &t.root4
+	}
+	return &t.root6
+}
+
+// Insert sets the value of p. Host bits of p are masked. An existing value
+// of the same prefix is replaced.
+func (t *Trie[V]) Insert(p netip.Prefix, v V) {
+	p = unmapPrefix(p).Masked()
+	leaf := &node[V]{prefix: p, hasValue: true, value: v}
+
+	n := t.root(p.Addr())
+	for {
+		cur := *n
+		if cur == nil {
+			*n = leaf
+			t.size++
+			return
+		}
+
+		common := commonBits(cur.prefix, p)
+		switch {
+		case common == cur.prefix.Bits() && common == p.Bits():
+			if !cur.hasValue {
+				t.size++
+			}
+			cur.hasValue, cur.value = true, v
+			return
+		case common == cur.prefix.Bits():
+			n = &cur.child[bitAt(p.Addr(), common)]
+			continue
+		case common == p.Bits():
+			leaf.child[bitAt(cur.prefix.Addr(), common)] = cur
+		default:
+			branch := &node[V]{prefix: netip.PrefixFrom(p.Addr(), common).Masked()}
+			branch.child[bitAt(p.Addr(), common)] = leaf
+			branch.child[bitAt(cur.prefix.Addr(), common)] = cur
+			leaf = branch
+		}
+		*n = leaf
+		t.size++
+		return
+	}
+}
+
+// InsertCIDR sets the value of the network of cidr.
+func (t *Trie[V]) InsertCIDR(cidr parser.CIDRInfo, v V) {
+	t.Insert(cidr.Prefix(), v)
+}
+
+// Get returns the value of exactly p.
+func (t *Trie[V]) Get(p netip.Prefix) (V, bool) {
+	p = unmapPrefix(p).Masked()
+	for n := *t.root(p.Addr()); n != nil && n.prefix.Bits() <= p.Bits() && n.prefix.Contains(p.Addr()); {
+		if n.prefix.Bits() == p.Bits() {
+			return n.value, n.hasValue
+		}
+		n = n.child[bitAt(p.Addr(), n.prefix.Bits())]
+	}
+
+	var zero V
+	return zero, false
+}
+
+// Lookup returns the longest prefix which contains addr and its value.
+func (t *Trie[V]) Lookup(addr netip.Addr) (netip.Prefix, V, bool) {
+	var found *node[V]
+	t.walkPath(addr.Unmap(), addr.Unmap().BitLen(), func(n *node[V]) {
+		found = n
+	})
+
+	if found == nil {
+		var zero V
+		return netip.Prefix{}, zero, false
+	}
+	return found.prefix, found.value, true
+}
+
+// LookupAll returns every prefix which contains addr, shortest first.
+func (t *Trie[V]) LookupAll(addr netip.Addr) []Entry[V] {
+	var entries []Entry[V]
+	t.walkPath(addr.Unmap(), addr.Unmap().BitLen(), func(n *node[V]) {
+		entries = append(entries, Entry[V]{n.prefix, n.value})
+	})
+	return entries
+}
+
+// WalkCovering calls fn for each prefix which covers p, including p
+// itself, shortest first until fn returns false.
+func (t *Trie[V]) WalkCovering(p netip.Prefix, fn func(e Entry[V]) bool) {
+	p = unmapPrefix(p).Masked()
+	stop := false
+	t.walkPath(p.Addr(), p.Bits(), func(n *node[V]) {
+		if !stop && !fn(Entry[V]{n.prefix, n.value}) {
+			stop = true
+		}
+	})
+}
+
+// WalkCovered calls fn for each prefix which is covered by p, including p
+// itself, in address order until fn returns false.
+func (t *Trie[V]) WalkCovered(p netip.Prefix, fn func(e Entry[V]) bool) {
+	p = unmapPrefix(p).Masked()
+	n := *t.root(p.Addr())
+	for n != nil && n.prefix.Bits() < p.Bits() {
+		if !n.prefix.Contains(p.Addr()) {
+			return
+		}
+		n = n.child[bitAt(p.Addr(), n.prefix.Bits())]
+	}
+	if n != nil && p.Contains(n.prefix.Addr()) {
+		walk(n, fn)
+	}
+}
+
+// Walk calls fn for each prefix in address order, IPv4 first, until fn
+// returns false. A prefix is visited before the prefixes it covers.
+func (t *Trie[V]) Walk(fn func(e Entry[V]) bool) {
+	if walk(t.root4, fn) {
+		walk(t.root6, fn)
+	}
+}
+
+// walkPath calls fn for each node with a value on the path to the first
+// maxBits bits of addr.
+func (t *Trie[V]) walkPath(addr netip.Addr, maxBits int, fn func(n *node[V])) {
+	for n := *t.root(addr); n != nil && n.prefix.Bits() <= maxBits && n.prefix.Contains(addr); {
+		if n.hasValue {
+			fn(n)
+		}
+		if n.prefix.Bits() == addr.BitLen() {
+			return
+		}
+		n = n.child[bitAt(addr, n.prefix.Bits())]
+	}
+}
+
+func walk[V any](n *node[V], fn func(e Entry[V]) bool) bool {
+	if n == nil {
+		return true
+	}
+	if n.hasValue && !fn(Entry[V]{n.prefix, n.value}) {
+		return false
+	}
+	return walk(n.child[0], fn) && walk(n.child[1], fn)
+}
+
+func unmapPrefix(p netip.Prefix) netip.Prefix {
+	if a := p.Addr(); a.Is4In6() && p.Bits() >= 96 {
+		return netip.PrefixFrom(a.Unmap(), p.Bits()-96)
+	}
+	return p
+}
+
+// bitAt returns the i-th bit of a from the most significant bit.
+func bitAt(a netip.Addr, i int) int {
+	if a.Is4() {
+		b := a.As4()
+		return int(b[i/8]>>uint(7-i%8)) & 1
+	}
+	b := a.As16()
+	return int(b[i/8]>>uint(7-i%8)) & 1
+}
+
+// commonBits returns the length of the common leading bits of a and b,
+// at most the shorter prefix length.
+func commonBits(a, b netip.Prefix) int {
+	max := a.Bits()
+	if b.Bits() < max {
+		max = b.Bits()
+	}
+
+	var x, y []byte
+	if a.Addr().Is4() {
+		xa, ya := a.Addr().As4(), b.Addr().As4()
+		x, y = xa[:], ya[:]
+	} else {
+		xa, ya := a.Addr().As16(), b.Addr().As16()
+		x, y = xa[:], ya[:]
+	}
+
+	n := 0
+	for i := range x {
+		d := x[i] ^ y[i]
+		if d != 0 {
+			n += bits.LeadingZeros8(d)
+			break
+		}
+		n += 8
+	}
+	if n > max {
+		n = max
+	}
+	return n
+}
@@ -0,0 +1,215 @@
+package trie
+
+import (
+	"math/rand"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+const prefixList = `# comment
+10.0.0.0/8      corp
+10.20.0.0/16    prod-vpc owner=netops
+10.20.30.0/24
+0.0.0.0/0	default
+
+2001:db8::/32   v6
+2001:db8:1::/48 v6-sub
+`
+
+func prefixStrings(entries []Entry[string]) []string {
+	var strs []string
+	for _, e := range entries {
+		strs = append(strs, e.Prefix.String()+" "+e.Value)
+	}
+	return strs
+}
+
+func loadTest(t *testing.T) *Trie[string] {
+	var cidrs []parser.CIDRInfo
+	for _, line := range strings.Split(prefixList, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cidr, e := parser.ParseLine(line)
+		if e != nil {
+			t.Fatalf("ParseLine(%s) error: %#v", line, e)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return FromCIDRs(cidrs)
+}
+
+var lookupTests = []struct {
+	addr     string
+	expected string
+}{
+	{"10.20.30.40", "10.20.30.0/24 10.20.30.0/24"},
+	{"10.20.31.40", "10.20.0.0/16 prod-vpc"},
+	{"10.21.0.1", "10.0.0.0/8 corp"},
+	{"192.168.0.1", "0.0.0.0/0 default"},
+	{"::ffff:10.21.0.1", "10.0.0.0/8 corp"},
+	{"2001:db8:1::1", "2001:db8:1::/48 v6-sub"},
+	{"2001:db8:2::1", "2001:db8::/32 v6"},
+	{"2001:db9::1", ""},
+}
+
+func TestLookup(t *testing.T) {
+	tr := loadTest(t)
+	if tr.Len() != 6 {
+		t.Errorf("Len actual: %d, expected: 6", tr.Len())
+	}
+
+	for _, lt := range lookupTests {
+		p, v, ok := tr.Lookup(netip.MustParseAddr(lt.addr))
+		actual := ""
+		if ok {
+			actual = p.String() + " " + v
+		}
+		if actual != lt.expected {
+			t.Errorf("Lookup(%s) actual: %q, expected: %q", lt.addr, actual, lt.expected)
+		}
+	}
+}
+
+func TestLookupAll(t *testing.T) {
+	tr := loadTest(t)
+	actual := prefixStrings(tr.LookupAll(netip.MustParseAddr("10.20.30.1")))
+	expected := []string{"0.0.0.0/0 default", "10.0.0.0/8 corp", "10.20.0.0/16 prod-vpc", "10.20.30.0/24 10.20.30.0/24"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("LookupAll actual: %v, expected: %v", actual, expected)
+	}
+}
+
+func TestWalkCoveredCovering(t *testing.T) {
+	tr := loadTest(t)
+
+	var covered []Entry[string]
+	tr.WalkCovered(netip.MustParsePrefix("10.0.0.0/8"), func(e Entry[string]) bool {
+		covered = append(covered, e)
+		return true
+	})
+	expected := []string{"10.0.0.0/8 corp", "10.20.0.0/16 prod-vpc", "10.20.30.0/24 10.20.30.0/24"}
+	if actual := prefixStrings(covered); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("WalkCovered actual: %v, expected: %v", actual, expected)
+	}
+
+	var covering []Entry[string]
+	tr.WalkCovering(netip.MustParsePrefix("10.20.0.0/17"), func(e Entry[string]) bool {
+		covering = append(covering, e)
+		return len(covering) < 2
+	})
+	expected = []string{"0.0.0.0/0 default", "10.0.0.0/8 corp"}
+	if actual := prefixStrings(covering); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("WalkCovering actual: %v, expected: %v", actual, expected)
+	}
+
+	var all []Entry[string]
+	tr.Walk(func(e Entry[string]) bool {
+		all = append(all, e)
+		return true
+	})
+	if len(all) != tr.Len() || all[0].Prefix.String() != "0.0.0.0/0" || all[len(all)-1].Prefix.String() != "2001:db8:1::/48" {
+		t.Errorf("Walk actual: %v", prefixStrings(all))
+	}
+}
+
+func TestGetAndReplace(t *testing.T) {
+	tr := loadTest(t)
+	tr.Insert(netip.MustParsePrefix("10.1.2.3/8"), "replaced")
+	if v, ok := tr.Get(netip.MustParsePrefix("10.0.0.0/8")); !ok || v != "replaced" || tr.Len() != 6 {
+		t.Errorf("Get actual: %s %v %d", v, ok, tr.Len())
+	}
+	if _, ok := tr.Get(netip.MustParsePrefix("10.0.0.0/9")); ok {
+		t.Errorf("Get of missing prefix found")
+	}
+}
+
+func TestFromCIDRsMetric(t *testing.T) {
+	var cidrs []parser.CIDRInfo
+	for _, line := range []string{
+		"0.0.0.0/0 via 192.168.1.1 dev eth0 metric=100",
+		"0.0.0.0/0 via 192.168.2.1 dev wlan0 metric=600",
+		"10.0.0.0/8 via 10.9.9.9 dev tun1 metric=50",
+		"10.0.0.0/8 via 10.0.0.1 dev tun0 metric=0",
+	} {
+		cidr, e := parser.ParseLine(line)
+		if e != nil {
+			t.Fatalf("ParseLine(%s) error: %#v", line, e)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+
+	tr := FromCIDRs(cidrs)
+	for addr, expected := range map[string]string{
+		"8.8.8.8":  "via 192.168.1.1 dev eth0",
+		"10.1.1.1": "via 10.0.0.1 dev tun0",
+	} {
+		if _, v, _ := tr.Lookup(netip.MustParseAddr(addr)); v != expected {
+			t.Errorf("Lookup(%s) actual: %s, expected: %s", addr, v, expected)
+		}
+	}
+}
+
+func randomPrefixes(r *rand.Rand, n int) []netip.Prefix {
+	prefixes := make([]netip.Prefix, n)
+	for i := range prefixes {
+		var b [4]byte
+		r.Read(b[:])
+		prefixes[i] = netip.PrefixFrom(netip.AddrFrom4(b), 8+r.Intn(25)).Masked()
+	}
+	return prefixes
+}
+
+func TestLookupMatchesLinear(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	prefixes := randomPrefixes(r, 2000)
+	tr := New[int]()
+	for i, p := range prefixes {
+		tr.Insert(p, i)
+	}
+
+	for i := 0; i < 2000; i++ {
+		var b [4]byte
+		r.Read(b[:])
+		addr := netip.AddrFrom4(b)
+		if i%2 == 0 {
+			addr = prefixes[i].Addr()
+		}
+
+		best := -1
+		for j, p := range prefixes {
+			if p.Contains(addr) && (best < 0 || p.Bits() > prefixes[best].Bits() || (p.Bits() == prefixes[best].Bits() && j > best)) {
+				best = j
+			}
+		}
+
+		_, v, ok := tr.Lookup(addr)
+		if ok != (best >= 0) || (ok && prefixes[v] != prefixes[best]) {
+			t.Fatalf("Lookup(%s) actual: %v %v, expected: %v", addr, v, ok, best)
+		}
+	}
+}
+
+func BenchmarkLookup(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	tr := New[int]()
+	for i, p := range randomPrefixes(r, 10000) {
+		tr.Insert(p, i)
+	}
+	addrs := make([]netip.Addr, 1024)
+	for i := range addrs {
+		var a [4]byte
+		r.Read(a[:])
+		addrs[i] = netip.AddrFrom4(a)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tr.Lookup(addrs[i%len(addrs)])
+	}
+}
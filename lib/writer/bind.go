@@ -0,0 +1,91 @@
+package writer
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// PTR records are listed for zones which have at most this many addresses
+const maxBindPTRs = 256
+
+// BindWriter writes a skeleton BIND reverse zone file for each zone of each
+// record. IPv4 networks from /25 to /31 get an RFC 2317 classless zone with
+// the delegation for the parent zone in comments.
+type BindWriter struct {
+	w    io.Writer
+	opts Options
+}
+
+func (bw *BindWriter) Begin() error {
+	return nil
+}
+
+func (bw *BindWriter) WriteCIDR(cidr parser.CIDRInfo) error {
+	p := &printer{w: bw.w}
+
+	if classless := cidr.ClasslessZone(); classless != "" {
+		r := cidr.Range()
+		parent := cidr.ReverseZones()[0]
+		p.printf("; %s\n", cidr.Prefix())
+		p.printf("; RFC 2317 delegation in the parent zone %s.:\n", parent)
+		p.printf(";   %s\tIN\tNS\t%s\n", strings.TrimSuffix(classless, "."+parent), bw.opts.NameServer)
+		p.printf(";   $GENERATE %d-%d $ IN CNAME $.%s.\n", r.From.As4()[3], r.To.As4()[3], classless)
+		bw.writeZone(p, classless, cidr.Prefix(), 1)
+		return p.err
+	}
+
+	for _, z := range cidr.ReverseZonePrefixes() {
+		p.printf("; %s\n", cidr.Prefix())
+		ptrs := z
+		if cidr.Bits() > z.Bits() {
+			ptrs = cidr.Prefix()
+		}
+		unit := 4
+		if z.Addr().Is4() {
+			unit = 8
+		}
+		bw.writeZone(p, parser.ReverseZoneName(z), ptrs, (z.Addr().BitLen()-z.Bits())/unit)
+	}
+
+	return p.err
+}
+
+// writeZone writes a zone whose records have ownerLabels labels, and PTR
+// records of the addresses in ptrs as comments.
+func (bw *BindWriter) writeZone(p *printer, origin string, ptrs netip.Prefix, ownerLabels int) {
+	p.printf("$ORIGIN %s.\n", origin)
+	p.printf("$TTL %d\n", bw.opts.TTL)
+	p.printf("@\tIN\tSOA\t%s %s (\n", bw.opts.NameServer, bw.opts.Hostmaster)
+	p.printf("\t\t\t1\t; serial\n")
+	p.printf("\t\t\t3600\t; refresh\n")
+	p.printf("\t\t\t900\t; retry\n")
+	p.printf("\t\t\t604800\t; expire\n")
+	p.printf("\t\t\t%d )\t; negative cache ttl\n", bw.opts.TTL)
+	p.printf("@\tIN\tNS\t%s\n", bw.opts.NameServer)
+
+	// only an example for large zones
+	count := 1
+	if n := ptrs.Addr().BitLen() - ptrs.Bits(); n < 64 && 1<<uint(n) <= maxBindPTRs {
+		count = 1 << uint(n)
+	}
+	a := ptrs.Addr()
+	for i := 0; i < count; i++ {
+		owner := strings.Join(parser.ReverseLabels(a, a.BitLen())[:ownerLabels], ".")
+		p.printf(";%s\tIN\tPTR\t%s\n", owner, bw.ptrName(a))
+		a = a.Next()
+	}
+	p.printf("\n")
+}
+
+func (bw *BindWriter) ptrName(a netip.Addr) string {
+	host := strings.NewReplacer(".", "-", ":", "-").Replace(a.String())
+	return fmt.Sprintf("host-%s.%s", host, bw.opts.Domain)
+}
+
+func (bw *BindWriter) End() error {
+	return nil
+}
@@ -0,0 +1,191 @@
+package writer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// dhcpScope is the DHCP subnet declaration of a network.
+type dhcpScope struct {
+	pools     [][2]netip.Addr
+	router    netip.Addr
+	broadcast netip.Addr
+}
+
+// newDHCPScope returns the scope of cidr. The pool is from Min to Max
+// without the reserved addresses at each end and the router.
+func newDHCPScope(cidr parser.CIDRInfo, opts Options) (dhcpScope, error) {
+	if cidr.Family() != parser.TYPE_IPV4 {
+		return dhcpScope{}, fmt.Errorf("DHCP scope of IPv6 CIDR %s is not supported\n", cidr.SrcCIDR)
+	}
+	if cidr.Bits() > 30 {
+		return dhcpScope{}, fmt.Errorf("CIDR %s has no addresses for a DHCP pool\n", cidr.SrcCIDR)
+	}
+
+	s := dhcpScope{router: cidr.MinAddr(), broadcast: cidr.BroadcastAddr()}
+	if opts.Router != "" {
+		s.router, _ = netip.ParseAddr(opts.Router)
+		// the network and broadcast addresses can not be the router
+		if r := u32(s.router); r < u32(cidr.MinAddr()) || r > u32(cidr.MaxAddr()) {
+			return dhcpScope{}, fmt.Errorf("router %s is not a usable address of CIDR %s\n", opts.Router, cidr.SrcCIDR)
+		}
+	}
+
+	usable := int64(u32(cidr.MaxAddr())) - int64(u32(cidr.MinAddr())) + 1
+	if int64(opts.ReserveLow)+int64(opts.ReserveHigh) >= usable {
+		return dhcpScope{}, fmt.Errorf("CIDR %s has no addresses for a DHCP pool\n", cidr.SrcCIDR)
+	}
+	first := u32(cidr.MinAddr()) + uint32(opts.ReserveLow)
+	last := u32(cidr.MaxAddr()) - uint32(opts.ReserveHigh)
+
+	r := u32(s.router)
+	switch {
+	case r < first || r > last:
+		s.pools = [][2]netip.Addr{{addr4(first), addr4(last)}}
+	case first == last:
+	case r == first:
+		s.pools = [][2]netip.Addr{{addr4(first + 1), addr4(last)}}
+	case r == last:
+		s.pools = [][2]netip.Addr{{addr4(first), addr4(last - 1)}}
+	default:
+		s.pools = [][2]netip.Addr{{addr4(first), addr4(r - 1)}, {addr4(r + 1), addr4(last)}}
+	}
+	if len(s.pools) == 0 {
+		return dhcpScope{}, fmt.Errorf("CIDR %s has no addresses for a DHCP pool\n", cidr.SrcCIDR)
+	}
+
+	return s, nil
+}
+
+// validateDHCP checks the options of the DHCP formats.
+func (o *Options) validateDHCP() error {
+	if o.Router != "" {
+		a, err := netip.ParseAddr(o.Router)
+		if err != nil || !a.Is4() {
+			return fmt.Errorf("router %s is not an IPv4 address\n", o.Router)
+		}
+	}
+	if o.ReserveLow < 0 || o.ReserveHigh < 0 {
+		return fmt.Errorf("number of reserved addresses must not be negative\n")
+	}
+	return nil
+}
+
+func u32(a netip.Addr) uint32 {
+	b := a.As4()
+	return binary.BigEndian.Uint32(b[:])
+}
+
+func addr4(n uint32) netip.Addr {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], n)
+	return netip.AddrFrom4(b)
+}
+
+// DhcpdWriter writes an ISC dhcpd subnet declaration for each record.
+type DhcpdWriter struct {
+	w    io.Writer
+	opts Options
+}
+
+func (dw *DhcpdWriter) Begin() error {
+	return nil
+}
+
+func (dw *DhcpdWriter) WriteCIDR(cidr parser.CIDRInfo) error {
+	s, err := newDHCPScope(cidr, dw.opts)
+	if err != nil {
+		return err
+	}
+
+	mask := mask2string(cidr.Mask)
+	p := &printer{w: dw.w}
+	p.printf("subnet %s netmask %s {\n", cidr.NetworkAddr(), mask)
+	if cidr.Label != "" {
+		p.printf("  # %s\n", cidr.Label)
+	}
+	for _, pool := range s.pools {
+		p.printf("  range %s %s;\n", pool[0], pool[1])
+	}
+	p.printf("  option routers %s;\n", s.router)
+	p.printf("  option subnet-mask %s;\n", mask)
+	p.printf("  option broadcast-address %s;\n", s.broadcast)
+	p.printf("}\n")
+	return p.err
+}
+
+func (dw *DhcpdWriter) End() error {
+	return nil
+}
+
+// KeaWriter writes a Kea DHCPv4 "subnet4" list which has a subnet for each
+// record. Subnet ids are numbered from 1 in input order.
+type KeaWriter struct {
+	w       io.Writer
+	opts    Options
+	subnets []keaSubnet
+}
+
+type keaSubnet struct {
+	ID          int               `json:"id"`
+	Subnet      string            `json:"subnet"`
+	Pools       []keaPool         `json:"pools"`
+	OptionData  []keaOption       `json:"option-data"`
+	UserContext map[string]string `json:"user-context,omitempty"`
+}
+
+type keaPool struct {
+	Pool string `json:"pool"`
+}
+
+type keaOption struct {
+	Name string `json:"name"`
+	Data string `json:"data"`
+}
+
+func (kw *KeaWriter) Begin() error {
+	return nil
+}
+
+func (kw *KeaWriter) WriteCIDR(cidr parser.CIDRInfo) error {
+	s, err := newDHCPScope(cidr, kw.opts)
+	if err != nil {
+		return err
+	}
+
+	sub := keaSubnet{
+		ID:     len(kw.subnets) + 1,
+		Subnet: cidr.Prefix().String(),
+		OptionData: []keaOption{
+			{Name: "routers", Data: s.router.String()},
+			{Name: "subnet-mask", Data: mask2string(cidr.Mask)},
+			{Name: "broadcast-address", Data: s.broadcast.String()},
+		},
+	}
+	for _, pool := range s.pools {
+		sub.Pools = append(sub.Pools, keaPool{Pool: pool[0].String() + " - " + pool[1].String()})
+	}
+	if cidr.Label != "" {
+		sub.UserContext = map[string]string{"comment": cidr.Label}
+	}
+	kw.subnets = append(kw.subnets, sub)
+	return nil
+}
+
+func (kw *KeaWriter) End() error {
+	subnets := kw.subnets
+	if subnets == nil {
+		subnets = []keaSubnet{}
+	}
+	b, err := json.MarshalIndent(map[string][]keaSubnet{"subnet4": subnets}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fpf(kw.w, "%s\n", b)
+	return err
+}
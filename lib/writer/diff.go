@@ -0,0 +1,110 @@
+package writer
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/goldeneggg/ipcl/lib/cidrlist"
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+type jsonDiff struct {
+	Old                string   `json:"old"`
+	New                string   `json:"new"`
+	Changed            bool     `json:"changed"`
+	Added              []string `json:"added"`
+	Removed            []string `json:"removed"`
+	Unchanged          []string `json:"unchanged"`
+	AddedAddresses     *big.Int `json:"added_addresses"`
+	RemovedAddresses   *big.Int `json:"removed_addresses"`
+	UnchangedAddresses *big.Int `json:"unchanged_addresses"`
+}
+
+// WriteDiff writes d of the lists named oldName and newName to Out in
+// format, which is FORMAT_DEFAULT, FORMAT_JSON or FORMAT_UNIFIED.
+func WriteDiff(format string, oldName string, newName string, d cidrlist.Diff) error {
+	if format == FORMAT_UNIFIED {
+		return writeDiffUnified(oldName, newName, d)
+	}
+	return writeReport(format, "diff", &diffReport{oldName, newName, d})
+}
+
+type diffReport struct {
+	oldName string
+	newName string
+	d       cidrlist.Diff
+}
+
+func (dr *diffReport) text(p *printer) {
+	d := dr.d
+	for _, s := range []struct {
+		name  string
+		cidrs []parser.CIDRInfo
+	}{
+		{"added", d.Added},
+		{"removed", d.Removed},
+		{"unchanged", d.Unchanged},
+	} {
+		for _, c := range s.cidrs {
+			p.printf("%-10s: %s (%s addresses)\n", s.name, c, c.AddrCount())
+		}
+	}
+	p.printf("total     : %s added, %s removed, %s unchanged addresses\n",
+		cidrlist.AddrCount(d.Added), cidrlist.AddrCount(d.Removed), cidrlist.AddrCount(d.Unchanged))
+}
+
+func (dr *diffReport) rows() [][]string {
+	return nil
+}
+
+func (dr *diffReport) jsonValue() interface{} {
+	d := dr.d
+	return jsonDiff{
+		Old:                dr.oldName,
+		New:                dr.newName,
+		Changed:            d.Changed(),
+		Added:              cidrStrings(d.Added),
+		Removed:            cidrStrings(d.Removed),
+		Unchanged:          cidrStrings(d.Unchanged),
+		AddedAddresses:     cidrlist.AddrCount(d.Added),
+		RemovedAddresses:   cidrlist.AddrCount(d.Removed),
+		UnchangedAddresses: cidrlist.AddrCount(d.Unchanged),
+	}
+}
+
+// writeDiffUnified writes the CIDRs of d in address order, each after "+"
+// if added, "-" if removed or " " if unchanged.
+func writeDiffUnified(oldName string, newName string, d cidrlist.Diff) error {
+	type line struct {
+		mark byte
+		cidr parser.CIDRInfo
+	}
+	var lines []line
+	for _, c := range d.Added {
+		lines = append(lines, line{'+', c})
+	}
+	for _, c := range d.Removed {
+		lines = append(lines, line{'-', c})
+	}
+	for _, c := range d.Unchanged {
+		lines = append(lines, line{' ', c})
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].cidr.Less(lines[j].cidr)
+	})
+
+	p := &printer{w: Out}
+	p.printf("--- %s\n+++ %s\n", oldName, newName)
+	for _, l := range lines {
+		p.printf("%c%s\n", l.mark, l.cidr)
+	}
+	return p.err
+}
+
+func cidrStrings(cidrs []parser.CIDRInfo) []string {
+	ss := []string{}
+	for _, c := range cidrs {
+		ss = append(ss, c.String())
+	}
+	return ss
+}
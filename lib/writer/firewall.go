@@ -0,0 +1,291 @@
+package writer
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/goldeneggg/ipcl/lib/ipset"
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// Firewall actions and directions of Options.
+const (
+	ACTION_ACCEPT = "accept"
+	ACTION_DROP   = "drop"
+
+	DIRECTION_IN  = "in"
+	DIRECTION_OUT = "out"
+)
+
+// validateFirewall checks the options of the firewall formats.
+func (o *Options) validateFirewall() error {
+	if o.Action != ACTION_ACCEPT && o.Action != ACTION_DROP {
+		return fmt.Errorf("action %s is not supported\n", o.Action)
+	}
+	if o.Direction != DIRECTION_IN && o.Direction != DIRECTION_OUT {
+		return fmt.Errorf("direction %s is not supported\n", o.Direction)
+	}
+	if o.Port < 0 || o.Port > 65535 {
+		return fmt.Errorf("port %d is out of range\n", o.Port)
+	}
+	return nil
+}
+
+func newFirewallWriter(format string, opts Options) Writer {
+	switch format {
+	case FORMAT_IPTABLES:
+		return &IptablesWriter{w: Out, opts: opts}
+	case FORMAT_NFTABLES:
+		return &NftablesWriter{w: Out, opts: opts}
+	case FORMAT_PF:
+		return &PfWriter{w: Out, opts: opts}
+	}
+	return &CiscoWriter{w: Out, opts: opts}
+}
+
+// IptablesWriter writes an iptables or ip6tables command for each record.
+// Inbound rules match the source address in the INPUT chain and outbound
+// rules the destination address in the OUTPUT chain. Labels are added as
+// comments.
+type IptablesWriter struct {
+	w    io.Writer
+	opts Options
+}
+
+func (iw *IptablesWriter) Begin() error {
+	return nil
+}
+
+func (iw *IptablesWriter) WriteCIDR(cidr parser.CIDRInfo) error {
+	cmd := "iptables"
+	if cidr.Family() == parser.TYPE_IPV6 {
+		cmd = "ip6tables"
+	}
+	chain, match := "INPUT", "-s"
+	if iw.opts.Direction == DIRECTION_OUT {
+		chain, match = "OUTPUT", "-d"
+	}
+
+	rule := []string{cmd, "-A", chain, match, cidr.Prefix().String()}
+	if iw.opts.Port > 0 {
+		rule = append(rule, "-p", "tcp", "--dport", fmt.Sprint(iw.opts.Port))
+	}
+	if cidr.Label != "" {
+		rule = append(rule, "-m", "comment", "--comment", shellQuote(cidr.Label))
+	}
+	rule = append(rule, "-j", strings.ToUpper(iw.opts.Action))
+
+	_, err := fpf(iw.w, "%s\n", strings.Join(rule, " "))
+	return err
+}
+
+func (iw *IptablesWriter) End() error {
+	return nil
+}
+
+// shellQuote quotes s in single quotes of POSIX shell, so no character of s
+// is expanded.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// firewallSets collects records by family for the formats which write them
+// as a set in End.
+type firewallSets struct {
+	v4 []string
+	v6 []string
+}
+
+func (fs *firewallSets) add(cidr parser.CIDRInfo) {
+	if cidr.Family() == parser.TYPE_IPV6 {
+		fs.v6 = append(fs.v6, cidr.Prefix().String())
+	} else {
+		fs.v4 = append(fs.v4, cidr.Prefix().String())
+	}
+}
+
+// NftablesWriter writes an nftables table which has an interval set for
+// each family and a filter chain with a rule for each set. Overlapping and
+// adjacent records are merged, because nft rejects overlapping elements of
+// an interval set.
+type NftablesWriter struct {
+	w     io.Writer
+	opts  Options
+	cidrs []parser.CIDRInfo
+}
+
+func (nw *NftablesWriter) Begin() error {
+	return nil
+}
+
+func (nw *NftablesWriter) WriteCIDR(cidr parser.CIDRInfo) error {
+	nw.cidrs = append(nw.cidrs, cidr)
+	return nil
+}
+
+func (nw *NftablesWriter) End() error {
+	hook, match := "input", "saddr"
+	if nw.opts.Direction == DIRECTION_OUT {
+		hook, match = "output", "daddr"
+	}
+	port := ""
+	if nw.opts.Port > 0 {
+		port = fmt.Sprintf(" tcp dport %d", nw.opts.Port)
+	}
+
+	var merged firewallSets
+	for _, cidr := range ipset.New(nw.cidrs...).CIDRs() {
+		merged.add(cidr)
+	}
+
+	p := &printer{w: nw.w}
+	p.printf("table inet %s {\n", nw.opts.Name)
+	sets := []struct {
+		name, typ, family string
+		elements          []string
+	}{
+		{nw.opts.Name + "_v4", "ipv4_addr", "ip", merged.v4},
+		{nw.opts.Name + "_v6", "ipv6_addr", "ip6", merged.v6},
+	}
+	for _, s := range sets {
+		if len(s.elements) == 0 {
+			continue
+		}
+		p.printf("\tset %s {\n", s.name)
+		p.printf("\t\ttype %s\n", s.typ)
+		p.printf("\t\tflags interval\n")
+		p.printf("\t\telements = { %s }\n", strings.Join(s.elements, ", "))
+		p.printf("\t}\n\n")
+	}
+	p.printf("\tchain %s {\n", hook)
+	p.printf("\t\ttype filter hook %s priority 0;\n", hook)
+	for _, s := range sets {
+		if len(s.elements) == 0 {
+			continue
+		}
+		p.printf("\t\t%s %s @%s%s %s\n", s.family, match, s.name, port, nw.opts.Action)
+	}
+	p.printf("\t}\n")
+	p.printf("}\n")
+
+	return p.err
+}
+
+// PfWriter writes a pf table of all records and a rule for the table.
+type PfWriter struct {
+	w    io.Writer
+	opts Options
+	firewallSets
+}
+
+func (pw *PfWriter) Begin() error {
+	return nil
+}
+
+func (pw *PfWriter) WriteCIDR(cidr parser.CIDRInfo) error {
+	pw.add(cidr)
+	return nil
+}
+
+func (pw *PfWriter) End() error {
+	action := "pass"
+	if pw.opts.Action == ACTION_DROP {
+		action = "block"
+	}
+	from, to := "<"+pw.opts.Name+">", "any"
+	if pw.opts.Direction == DIRECTION_OUT {
+		from, to = to, from
+	}
+	proto, port := "", ""
+	if pw.opts.Port > 0 {
+		proto = " proto tcp"
+		port = fmt.Sprintf(" port %d", pw.opts.Port)
+	}
+
+	p := &printer{w: pw.w}
+	p.printf("table <%s> { %s }\n", pw.opts.Name, strings.Join(append(pw.v4, pw.v6...), ", "))
+	p.printf("%s %s quick%s from %s to %s%s\n", action, pw.opts.Direction, proto, from, to, port)
+
+	return p.err
+}
+
+// CiscoWriter writes a Cisco IOS extended access list of IPv4 records with
+// wildcard masks, and an IPv6 access list of IPv6 records. Labels are
+// written as remarks.
+type CiscoWriter struct {
+	w    io.Writer
+	opts Options
+	v4   []string
+	v6   []string
+}
+
+func (cw *CiscoWriter) Begin() error {
+	return nil
+}
+
+func (cw *CiscoWriter) WriteCIDR(cidr parser.CIDRInfo) error {
+	action := "permit"
+	if cw.opts.Action == ACTION_DROP {
+		action = "deny"
+	}
+	proto, port := "ip", ""
+	if cidr.Family() == parser.TYPE_IPV6 {
+		proto = "ipv6"
+	}
+	if cw.opts.Port > 0 {
+		proto = "tcp"
+		port = fmt.Sprintf(" eq %d", cw.opts.Port)
+	}
+
+	var addr string
+	switch {
+	case cidr.Family() == parser.TYPE_IPV6:
+		addr = cidr.Prefix().String()
+	case cidr.Bits() == 32:
+		addr = "host " + cidr.NetworkAddr().String()
+	default:
+		addr = cidr.NetworkAddr().String() + " " + wildcard(cidr.Mask)
+	}
+	src, dst := addr, "any"
+	if cw.opts.Direction == DIRECTION_OUT {
+		src, dst = dst, src
+	}
+
+	var entries []string
+	if cidr.Label != "" {
+		entries = append(entries, " remark "+cidr.Label)
+	}
+	entries = append(entries, fmt.Sprintf(" %s %s %s %s%s", action, proto, src, dst, port))
+	if cidr.Family() == parser.TYPE_IPV6 {
+		cw.v6 = append(cw.v6, entries...)
+	} else {
+		cw.v4 = append(cw.v4, entries...)
+	}
+	return nil
+}
+
+func (cw *CiscoWriter) End() error {
+	p := &printer{w: cw.w}
+	if len(cw.v4) > 0 {
+		p.printf("ip access-list extended %s\n", cw.opts.Name)
+		p.printf("%s\n", strings.Join(cw.v4, "\n"))
+	}
+	if len(cw.v6) > 0 {
+		p.printf("ipv6 access-list %s\n", cw.opts.Name)
+		p.printf("%s\n", strings.Join(cw.v6, "\n"))
+	}
+
+	return p.err
+}
+
+// wildcard returns the inverse of mask in dotted decimal, such as
+// "0.0.0.255" for 255.255.255.0.
+func wildcard(mask net.IPMask) string {
+	w := make([]byte, len(mask))
+	for i, m := range mask {
+		w[i] = ^m
+	}
+	return mask2string(w)
+}
@@ -0,0 +1,177 @@
+package writer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// records which have this attribute are written as except entries of the
+// ipBlock which contains them by KubernetesWriter
+const exceptAttr = "except"
+
+// TerraformWriter writes a Terraform locals block which has a list of the
+// records. Labels are written as comments.
+type TerraformWriter struct {
+	w    io.Writer
+	opts Options
+}
+
+func (tw *TerraformWriter) Begin() error {
+	_, err := fpf(tw.w, "locals {\n  %s = [\n", tw.opts.Name)
+	return err
+}
+
+func (tw *TerraformWriter) WriteCIDR(cidr parser.CIDRInfo) error {
+	comment := ""
+	if cidr.Label != "" {
+		comment = " # " + cidr.Label
+	}
+	_, err := fpf(tw.w, "    %s,%s\n", strconv.Quote(cidr.Prefix().String()), comment)
+	return err
+}
+
+func (tw *TerraformWriter) End() error {
+	_, err := fpf(tw.w, "  ]\n}\n")
+	return err
+}
+
+// AWSWriter writes the SecurityGroupIngress or SecurityGroupEgress property
+// of an AWS::EC2::SecurityGroup in JSON or YAML. Security groups only allow
+// traffic, so the drop action is not supported.
+type AWSWriter struct {
+	w     io.Writer
+	opts  Options
+	yaml  bool
+	rules []awsRule
+}
+
+type awsRule struct {
+	IpProtocol  string `json:"IpProtocol"`
+	FromPort    int    `json:"FromPort,omitempty"`
+	ToPort      int    `json:"ToPort,omitempty"`
+	CidrIp      string `json:"CidrIp,omitempty"`
+	CidrIpv6    string `json:"CidrIpv6,omitempty"`
+	Description string `json:"Description,omitempty"`
+}
+
+func (aw *AWSWriter) Begin() error {
+	return nil
+}
+
+func (aw *AWSWriter) WriteCIDR(cidr parser.CIDRInfo) error {
+	r := awsRule{IpProtocol: "-1", Description: cidr.Label}
+	if aw.opts.Port > 0 {
+		r.IpProtocol = "tcp"
+		r.FromPort = aw.opts.Port
+		r.ToPort = aw.opts.Port
+	}
+	if cidr.Family() == parser.TYPE_IPV6 {
+		r.CidrIpv6 = cidr.Prefix().String()
+	} else {
+		r.CidrIp = cidr.Prefix().String()
+	}
+	aw.rules = append(aw.rules, r)
+	return nil
+}
+
+func (aw *AWSWriter) End() error {
+	key := "SecurityGroupIngress"
+	if aw.opts.Direction == DIRECTION_OUT {
+		key = "SecurityGroupEgress"
+	}
+
+	if !aw.yaml {
+		rules := aw.rules
+		if rules == nil {
+			rules = []awsRule{}
+		}
+		b, err := json.MarshalIndent(map[string][]awsRule{key: rules}, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fpf(aw.w, "%s\n", b)
+		return err
+	}
+
+	p := &printer{w: aw.w}
+	if len(aw.rules) == 0 {
+		p.printf("%s: []\n", key)
+		return p.err
+	}
+	p.printf("%s:\n", key)
+	for _, r := range aw.rules {
+		p.printf("  - IpProtocol: %s\n", strconv.Quote(r.IpProtocol))
+		if r.FromPort > 0 {
+			p.printf("    FromPort: %d\n", r.FromPort)
+			p.printf("    ToPort: %d\n", r.ToPort)
+		}
+		if r.CidrIp != "" {
+			p.printf("    CidrIp: %s\n", r.CidrIp)
+		} else {
+			p.printf("    CidrIpv6: %s\n", r.CidrIpv6)
+		}
+		if r.Description != "" {
+			p.printf("    Description: %s\n", strconv.Quote(r.Description))
+		}
+	}
+	return p.err
+}
+
+// KubernetesWriter writes the records as a list of NetworkPolicy ipBlock
+// peers. A record which has the "except" attribute is written in the except
+// list of the first ipBlock which contains it.
+type KubernetesWriter struct {
+	w      io.Writer
+	blocks []parser.CIDRInfo
+	except []parser.CIDRInfo
+}
+
+func (kw *KubernetesWriter) Begin() error {
+	return nil
+}
+
+func (kw *KubernetesWriter) WriteCIDR(cidr parser.CIDRInfo) error {
+	if _, ok := cidr.Attr(exceptAttr); ok {
+		kw.except = append(kw.except, cidr)
+	} else {
+		kw.blocks = append(kw.blocks, cidr)
+	}
+	return nil
+}
+
+func (kw *KubernetesWriter) End() error {
+	excepts := make([][]string, len(kw.blocks))
+	for _, ex := range kw.except {
+		found := false
+		for i, b := range kw.blocks {
+			if b.Family() == ex.Family() && b.Bits() < ex.Bits() && b.ContainsAddr(ex.NetworkAddr()) {
+				excepts[i] = append(excepts[i], ex.Prefix().String())
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("except CIDR %s is not in any ipBlock\n", ex.SrcCIDR)
+		}
+	}
+
+	p := &printer{w: kw.w}
+	for i, b := range kw.blocks {
+		if b.Label != "" {
+			p.printf("# %s\n", b.Label)
+		}
+		p.printf("- ipBlock:\n")
+		p.printf("    cidr: %s\n", b.Prefix())
+		if len(excepts[i]) > 0 {
+			p.printf("    except:\n")
+			for _, e := range excepts[i] {
+				p.printf("    - %s\n", e)
+			}
+		}
+	}
+	return p.err
+}
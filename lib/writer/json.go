@@ -0,0 +1,154 @@
+package writer
+
+import (
+	"encoding/json"
+	"io"
+	"math/big"
+	"net"
+
+	"github.com/goldeneggg/ipcl/lib/cidrlist"
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// JSONWriter writes records as a JSON array of objects which have the
+// same keys as the csv header. Subtotals of groups and the summary are
+// written as trailing objects which have a "subtotal" or "summary" key.
+type JSONWriter struct {
+	w       io.Writer
+	n       int
+	summary *cidrlist.Summary
+}
+
+type jsonRecord struct {
+	SourceCIDR  string            `json:"source_cidr"`
+	Network     string            `json:"network"`
+	Mask        string            `json:"mask"`
+	HostNum     *big.Int          `json:"host_num"`
+	MinAddress  *string           `json:"min_address"`
+	MaxAddress  *string           `json:"max_address"`
+	Broadcast   *string           `json:"broadcast"`
+	ReverseZone []string          `json:"reverse_zone"`
+	Label       string            `json:"label,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+}
+
+type jsonSubtotal struct {
+	Subtotal struct {
+		Group   string   `json:"group"`
+		CIDRs   int      `json:"cidrs"`
+		HostNum *big.Int `json:"host_num"`
+	} `json:"subtotal"`
+}
+
+type jsonStats struct {
+	Family   string   `json:"family,omitempty"`
+	CIDRs    int      `json:"cidrs"`
+	Covered  *big.Int `json:"covered"`
+	Largest  *string  `json:"largest"`
+	Smallest *string  `json:"smallest"`
+}
+
+type jsonSummary struct {
+	Summary struct {
+		Inputs   int `json:"inputs"`
+		Rejected int `json:"rejected"`
+		jsonStats
+		Families []jsonStats `json:"families"`
+	} `json:"summary"`
+}
+
+func (jw *JSONWriter) Begin() error {
+	_, err := fpf(jw.w, "[")
+	return err
+}
+
+func (jw *JSONWriter) WriteCIDR(cidr parser.CIDRInfo) error {
+	r := jsonRecord{
+		SourceCIDR:  cidr.SrcCIDR,
+		Network:     cidr.Network.String(),
+		Mask:        mask2string(cidr.Mask),
+		HostNum:     cidr.HostCount(),
+		MinAddress:  ipString(cidr.Min),
+		MaxAddress:  ipString(cidr.Max),
+		Broadcast:   ipString(cidr.Broadcast),
+		ReverseZone: cidr.ReverseZones(),
+		Label:       cidr.Label,
+	}
+	if len(cidr.Attrs) > 0 {
+		r.Attributes = make(map[string]string)
+		for _, a := range cidr.Attrs {
+			r.Attributes[a.Key] = a.Value
+		}
+	}
+
+	return jw.writeElem(r)
+}
+
+func (jw *JSONWriter) End() error {
+	if jw.summary != nil {
+		var js jsonSummary
+		js.Summary.Inputs = jw.summary.Inputs
+		js.Summary.Rejected = jw.summary.Rejected
+		js.Summary.jsonStats = newJSONStats("", jw.summary.Stats)
+		js.Summary.Families = []jsonStats{}
+		for _, f := range jw.summary.Families {
+			js.Summary.Families = append(js.Summary.Families, newJSONStats(f.Family, f.Stats))
+		}
+		if err := jw.writeElem(js); err != nil {
+			return err
+		}
+	}
+
+	_, err := fpf(jw.w, "\n]\n")
+	return err
+}
+
+// SetSummary sets the summary written by End.
+func (jw *JSONWriter) SetSummary(s cidrlist.Summary) {
+	jw.summary = &s
+}
+
+func (jw *JSONWriter) BeginGroup(g cidrlist.Group) error {
+	return nil
+}
+
+func (jw *JSONWriter) EndGroup(g cidrlist.Group) error {
+	var js jsonSubtotal
+	js.Subtotal.Group = g.Name
+	js.Subtotal.CIDRs = len(g.CIDRs)
+	js.Subtotal.HostNum = g.HostCount()
+	return jw.writeElem(js)
+}
+
+func (jw *JSONWriter) writeElem(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	sep := ","
+	if jw.n == 0 {
+		sep = ""
+	}
+	jw.n++
+	_, err = fpf(jw.w, "%s\n  %s", sep, b)
+	return err
+}
+
+func newJSONStats(family string, st cidrlist.Stats) jsonStats {
+	js := jsonStats{Family: family, CIDRs: st.Count, Covered: st.Covered}
+	if st.Count > 0 {
+		largest, smallest := st.Largest.String(), st.Smallest.String()
+		js.Largest, js.Smallest = &largest, &smallest
+	}
+	return js
+}
+
+// ipString returns nil for a nil ip to write null.
+func ipString(ip net.IP) *string {
+	if ip == nil {
+		return nil
+	}
+	s := ip.String()
+	return &s
+}
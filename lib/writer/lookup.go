@@ -0,0 +1,63 @@
+package writer
+
+import (
+	"net/netip"
+)
+
+// LookupResult is the longest matching prefix of an IP address and its
+// label. Prefix is invalid if no prefix matches.
+type LookupResult struct {
+	IP     string
+	Prefix netip.Prefix
+	Label  string
+}
+
+type jsonLookup struct {
+	IP     string  `json:"ip"`
+	Prefix *string `json:"prefix"`
+	Label  string  `json:"label,omitempty"`
+}
+
+// WriteLookup writes results to Out in format, which is FORMAT_DEFAULT,
+// FORMAT_CSV, FORMAT_TSV or FORMAT_JSON. The default format is tab separated
+// without a header.
+func WriteLookup(format string, results []LookupResult) error {
+	return writeReport(format, "lookup", lookupReport(results))
+}
+
+type lookupReport []LookupResult
+
+func (lr lookupReport) text(p *printer) {
+	for _, r := range lr {
+		prefix := ""
+		if r.Prefix.IsValid() {
+			prefix = r.Prefix.String()
+		}
+		p.printf("%s\t%s\t%s\n", r.IP, prefix, r.Label)
+	}
+}
+
+func (lr lookupReport) rows() [][]string {
+	rows := [][]string{{"ip", "prefix", "label"}}
+	for _, r := range lr {
+		prefix := ""
+		if r.Prefix.IsValid() {
+			prefix = r.Prefix.String()
+		}
+		rows = append(rows, []string{r.IP, prefix, r.Label})
+	}
+	return rows
+}
+
+func (lr lookupReport) jsonValue() interface{} {
+	records := []jsonLookup{}
+	for _, r := range lr {
+		jl := jsonLookup{IP: r.IP, Label: r.Label}
+		if r.Prefix.IsValid() {
+			prefix := r.Prefix.String()
+			jl.Prefix = &prefix
+		}
+		records = append(records, jl)
+	}
+	return records
+}
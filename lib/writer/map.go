@@ -0,0 +1,176 @@
+package writer
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// Cells of the map.
+const (
+	MAP_FREE    = '.'
+	MAP_SHARED  = '+' // several allocations which do not overlap
+	MAP_OVERLAP = '!' // allocations which overlap each other
+	MAP_MORE    = '#' // allocations after the keys run out
+
+	mapKeys    = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	mapColumns = 16
+	maxMapBits = 12 // at most 1 << maxMapBits cells
+)
+
+// ANSI colors of keys, free cells are not colored
+var (
+	mapColors   = []string{"\x1b[32m", "\x1b[34m", "\x1b[35m", "\x1b[36m", "\x1b[33m"}
+	mapAlert    = "\x1b[31m"
+	mapColorEnd = "\x1b[0m"
+)
+
+// MapCellBits returns the default prefix length of the cells of the map of
+// parent, which has at most 256 cells.
+func MapCellBits(parent parser.CIDRInfo) int {
+	bits := parent.Bits() + 8
+	if max := parent.NetworkAddr().BitLen(); bits > max {
+		bits = max
+	}
+	return bits
+}
+
+// WriteMap writes a grid of parent to Out in format, which is
+// FORMAT_DEFAULT. Each cell is a network of prefix length cellBits, marked
+// with the key of the allocation in cidrs which covers it, MAP_FREE,
+// MAP_SHARED or MAP_OVERLAP. Keys are given in address order and a legend
+// of the keys follows the grid. Keys are colored with ANSI escapes if color
+// is true.
+func WriteMap(format string, parent parser.CIDRInfo, cidrs []parser.CIDRInfo, cellBits int, color bool) error {
+	bitLen := parent.NetworkAddr().BitLen()
+	if cellBits < parent.Bits() || cellBits > bitLen {
+		return fmt.Errorf("cell prefix length %d is out of %s\n", cellBits, parent)
+	}
+	if cellBits-parent.Bits() > maxMapBits {
+		return fmt.Errorf("map of %s has more than %d cells of /%d\n", parent, 1<<maxMapBits, cellBits)
+	}
+
+	var allocs []parser.CIDRInfo
+	for _, c := range cidrs {
+		if c.Prefix().Overlaps(parent.Prefix()) {
+			allocs = append(allocs, c)
+		}
+	}
+	sort.SliceStable(allocs, func(i, j int) bool {
+		return allocs[i].Less(allocs[j])
+	})
+
+	return writeReport(format, "map", &mapReport{parent, allocs, cellBits, color})
+}
+
+type mapReport struct {
+	parent   parser.CIDRInfo
+	allocs   []parser.CIDRInfo // in address order
+	cellBits int
+	color    bool
+}
+
+func (mr *mapReport) text(p *printer) {
+	parent, allocs, cellBits, color := mr.parent, mr.allocs, mr.cellBits, mr.color
+	cells := 1 << uint(cellBits-parent.Bits())
+	p.printf("%s, %d cells of /%d\n\n", parent, cells, cellBits)
+
+	width := len(parent.NetworkAddr().String())
+	if n := len(parent.Range().To.String()); n > width {
+		width = n
+	}
+
+	alert, shared := false, false
+	cur := parent.NetworkAddr()
+	for i := 0; i < cells; i++ {
+		if i%mapColumns == 0 {
+			if i > 0 {
+				p.printf("\n")
+			}
+			p.printf("%-*s ", width, cur)
+		}
+
+		cell := netip.PrefixFrom(cur, cellBits)
+		var in []int
+		for j, a := range allocs {
+			if a.Prefix().Overlaps(cell) {
+				in = append(in, j)
+			}
+		}
+
+		switch {
+		case len(in) == 0:
+			p.printf("%c", MAP_FREE)
+		case len(in) == 1:
+			p.printf("%s", colored(mapKey(in[0]), in[0], color))
+		case overlaps(allocs, in):
+			alert = true
+			p.printf("%s", colored(MAP_OVERLAP, -1, color))
+		default:
+			shared = true
+			p.printf("%s", colored(MAP_SHARED, -1, color))
+		}
+
+		cur = parser.FromPrefix(cell).Range().To.Next()
+	}
+	p.printf("\n\n")
+
+	p.printf("%c free\n", MAP_FREE)
+	for j, a := range allocs {
+		p.printf("%s %s", colored(mapKey(j), j, color), a.Prefix())
+		if a.Label != "" {
+			p.printf(" %s", a.Label)
+		}
+		p.printf("\n")
+	}
+	if shared {
+		p.printf("%c several allocations\n", MAP_SHARED)
+	}
+	if alert {
+		p.printf("%s overlapping allocations\n", colored(MAP_OVERLAP, -1, color))
+	}
+
+}
+
+func (mr *mapReport) rows() [][]string {
+	return nil
+}
+
+func (mr *mapReport) jsonValue() interface{} {
+	return nil
+}
+
+func mapKey(i int) rune {
+	if i < len(mapKeys) {
+		return rune(mapKeys[i])
+	}
+	return MAP_MORE
+}
+
+// overlaps reports whether any two allocations of in overlap.
+func overlaps(allocs []parser.CIDRInfo, in []int) bool {
+	for x := range in {
+		for y := x + 1; y < len(in); y++ {
+			if allocs[in[x]].Prefix().Overlaps(allocs[in[y]].Prefix()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// colored returns the cell c in the color of the i-th key. MAP_OVERLAP is in
+// the alert color and other cells of negative i are not colored.
+func colored(c rune, i int, color bool) string {
+	switch {
+	case !color:
+		return string(c)
+	case c == MAP_OVERLAP:
+		return mapAlert + string(c) + mapColorEnd
+	case i < 0:
+		return string(c)
+	}
+	return mapColors[i%len(mapColors)] + string(c) + mapColorEnd
+}
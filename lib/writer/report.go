@@ -0,0 +1,57 @@
+package writer
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// report is a result of a command which is not a list of CIDRs, such as a
+// usage report. writeReport writes it in an output format.
+type report interface {
+	// text writes the report in FORMAT_DEFAULT.
+	text(p *printer)
+	// rows returns the rows of FORMAT_CSV and FORMAT_TSV with the header
+	// first, or nil if the report has no table.
+	rows() [][]string
+	// jsonValue returns the value of FORMAT_JSON, or nil if the report has
+	// no json.
+	jsonValue() interface{}
+}
+
+// writeReport writes r to Out in format. name is the name of the report in
+// the error of an unsupported format.
+func writeReport(format string, name string, r report) error {
+	switch format {
+	case FORMAT_DEFAULT, "":
+		p := &printer{w: Out}
+		r.text(p)
+		return p.err
+	case FORMAT_CSV, FORMAT_TSV:
+		rows := r.rows()
+		if rows == nil {
+			break
+		}
+		sep := ","
+		if format == FORMAT_TSV {
+			sep = "\t"
+		}
+		for _, row := range rows {
+			if err := writeRow(Out, sep, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FORMAT_JSON:
+		v := r.jsonValue()
+		if v == nil {
+			break
+		}
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fpf(Out, "%s\n", b)
+		return err
+	}
+	return fmt.Errorf("output format %s is not supported by %s\n", format, name)
+}
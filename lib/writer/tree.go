@@ -0,0 +1,82 @@
+package writer
+
+import (
+	"math/big"
+
+	"github.com/goldeneggg/ipcl/lib/cidrlist"
+)
+
+type jsonNode struct {
+	CIDR                 string      `json:"cidr"`
+	Label                string      `json:"label,omitempty"`
+	HostNum              *big.Int    `json:"host_num"`
+	Unallocated          []string    `json:"unallocated,omitempty"`
+	UnallocatedAddresses *big.Int    `json:"unallocated_addresses,omitempty"`
+	Children             []*jsonNode `json:"children,omitempty"`
+}
+
+// WriteTree writes the containment tree of roots to Out in format, which is
+// FORMAT_DEFAULT or FORMAT_JSON. Each node which has children shows the
+// addresses which are not in any child.
+func WriteTree(format string, roots []*cidrlist.Node) error {
+	return writeReport(format, "tree", treeReport(roots))
+}
+
+type treeReport []*cidrlist.Node
+
+func (tr treeReport) text(p *printer) {
+	for _, n := range tr {
+		writeNode(p, n, "", "")
+	}
+}
+
+func (tr treeReport) rows() [][]string {
+	return nil
+}
+
+func (tr treeReport) jsonValue() interface{} {
+	nodes := []*jsonNode{}
+	for _, n := range tr {
+		nodes = append(nodes, toJSONNode(n))
+	}
+	return nodes
+}
+
+// writeNode writes n after head and its children after indent.
+func writeNode(p *printer, n *cidrlist.Node, head string, indent string) {
+	p.printf("%s%s", head, n.CIDR)
+	if n.CIDR.Label != "" {
+		p.printf(" %s", n.CIDR.Label)
+	}
+	p.printf(" (%s hosts", n.CIDR.HostCount())
+	if len(n.Children) > 0 {
+		p.printf(", %s unallocated", n.UnallocatedCount())
+	}
+	p.printf(")\n")
+
+	for i, c := range n.Children {
+		if i == len(n.Children)-1 {
+			writeNode(p, c, indent+"└── ", indent+"    ")
+		} else {
+			writeNode(p, c, indent+"├── ", indent+"│   ")
+		}
+	}
+}
+
+func toJSONNode(n *cidrlist.Node) *jsonNode {
+	jn := &jsonNode{
+		CIDR:    n.CIDR.String(),
+		Label:   n.CIDR.Label,
+		HostNum: n.CIDR.HostCount(),
+	}
+	if len(n.Children) > 0 {
+		for _, c := range n.Unallocated() {
+			jn.Unallocated = append(jn.Unallocated, c.String())
+		}
+		jn.UnallocatedAddresses = n.UnallocatedCount()
+	}
+	for _, c := range n.Children {
+		jn.Children = append(jn.Children, toJSONNode(c))
+	}
+	return jn
+}
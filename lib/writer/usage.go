@@ -0,0 +1,142 @@
+package writer
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/goldeneggg/ipcl/lib/cidrlist"
+)
+
+var usageHeaders = []string{"parent",
+	"cidr",
+	"label",
+	"addresses",
+	"used",
+	"free",
+	"used_percent",
+	"free_blocks",
+	"largest_free",
+	"largest_free_cidr"}
+
+type jsonUsage struct {
+	Parent          string           `json:"parent"`
+	Label           string           `json:"label,omitempty"`
+	Addresses       *big.Int         `json:"addresses"`
+	Used            *big.Int         `json:"used"`
+	Free            *big.Int         `json:"free"`
+	UsedPercent     float64          `json:"used_percent"`
+	FreeBlocks      int              `json:"free_blocks"`
+	LargestFree     *string          `json:"largest_free"`
+	LargestFreeCIDR *string          `json:"largest_free_cidr"`
+	Children        []jsonChildUsage `json:"children"`
+}
+
+type jsonChildUsage struct {
+	CIDR        string   `json:"cidr"`
+	Label       string   `json:"label,omitempty"`
+	Addresses   *big.Int `json:"addresses"`
+	UsedPercent float64  `json:"used_percent"`
+}
+
+// WriteUsage writes usages to Out in format, which is FORMAT_DEFAULT,
+// FORMAT_CSV, FORMAT_TSV or FORMAT_JSON. Rows of csv and tsv are a row for
+// each parent followed by a row for each child.
+func WriteUsage(format string, usages []cidrlist.Usage) error {
+	return writeReport(format, "usage report", usageReport(usages))
+}
+
+type usageReport []cidrlist.Usage
+
+func (ur usageReport) text(p *printer) {
+	for _, u := range ur {
+		p.printf("parent      : %s\n", u.Parent)
+		if u.Parent.Label != "" {
+			p.printf("label       : %s\n", u.Parent.Label)
+		}
+		p.printf("addresses   : %s\n", u.Addresses)
+		p.printf("used        : %s (%.2f%%)\n", u.Used, u.Percent(u.Used))
+		p.printf("free        : %s (%.2f%%)\n", u.Free, u.Percent(u.Free))
+		p.printf("free blocks : %d\n", u.FreeBlocks)
+		if u.LargestCIDR.IsValid() {
+			p.printf("largest free: %s (%s addresses), %s\n", u.LargestFree, u.LargestFree.AddrCount(), u.LargestCIDR)
+		}
+		for _, c := range u.Children {
+			p.printf("child       : %-18s %10s addresses %6.2f%%", c.CIDR, c.Addresses, u.Percent(c.Addresses))
+			if c.CIDR.Label != "" {
+				p.printf(" %s", c.CIDR.Label)
+			}
+			p.printf("\n")
+		}
+		p.printf("\n")
+	}
+}
+
+func (ur usageReport) rows() [][]string {
+	rows := [][]string{usageHeaders}
+	for _, u := range ur {
+		row := []string{u.Parent.String(),
+			u.Parent.String(),
+			u.Parent.Label,
+			u.Addresses.String(),
+			u.Used.String(),
+			u.Free.String(),
+			fmt.Sprintf("%.2f", u.Percent(u.Used)),
+			fmt.Sprint(u.FreeBlocks),
+			"",
+			""}
+		if u.LargestCIDR.IsValid() {
+			row[8] = u.LargestFree.String()
+			row[9] = u.LargestCIDR.String()
+		}
+		rows = append(rows, row)
+
+		for _, c := range u.Children {
+			row := make([]string, len(usageHeaders))
+			row[0] = u.Parent.String()
+			row[1] = c.CIDR.String()
+			row[2] = c.CIDR.Label
+			row[3] = c.Addresses.String()
+			row[4] = c.Addresses.String()
+			row[6] = fmt.Sprintf("%.2f", u.Percent(c.Addresses))
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func (ur usageReport) jsonValue() interface{} {
+	records := []jsonUsage{}
+	for _, u := range ur {
+		r := jsonUsage{
+			Parent:      u.Parent.String(),
+			Label:       u.Parent.Label,
+			Addresses:   u.Addresses,
+			Used:        u.Used,
+			Free:        u.Free,
+			UsedPercent: round2(u.Percent(u.Used)),
+			FreeBlocks:  u.FreeBlocks,
+			Children:    []jsonChildUsage{},
+		}
+		if u.LargestCIDR.IsValid() {
+			largest, cidr := u.LargestFree.String(), u.LargestCIDR.String()
+			r.LargestFree, r.LargestFreeCIDR = &largest, &cidr
+		}
+		for _, c := range u.Children {
+			r.Children = append(r.Children, jsonChildUsage{
+				CIDR:        c.CIDR.String(),
+				Label:       c.CIDR.Label,
+				Addresses:   c.Addresses,
+				UsedPercent: round2(u.Percent(c.Addresses)),
+			})
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+// round2 rounds f to 2 decimal places like the text and csv formats.
+func round2(f float64) float64 {
+	r, _ := strconv.ParseFloat(fmt.Sprintf("%.2f", f), 64)
+	return r
+}
@@ -0,0 +1,178 @@
+package writer
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// Default policies of Options. The records get the opposite of the default
+// policy, so POLICY_DENY writes an allow-list.
+const (
+	POLICY_ALLOW = "allow"
+	POLICY_DENY  = "deny"
+)
+
+func (o *Options) validatePolicy() error {
+	if o.Policy != POLICY_ALLOW && o.Policy != POLICY_DENY {
+		return fmt.Errorf("policy %s is not supported\n", o.Policy)
+	}
+	return nil
+}
+
+func newWebWriter(format string, opts Options) Writer {
+	switch format {
+	case FORMAT_NGINX:
+		return &NginxWriter{w: Out, opts: opts}
+	case FORMAT_APACHE:
+		return &ApacheWriter{w: Out, opts: opts}
+	case FORMAT_HAPROXY:
+		return &HAProxyWriter{w: Out, opts: opts}
+	}
+	return &EnvoyWriter{w: Out, opts: opts}
+}
+
+// NginxWriter writes allow or deny directives of the records followed by
+// the default policy for all other addresses.
+type NginxWriter struct {
+	w    io.Writer
+	opts Options
+}
+
+func (nw *NginxWriter) Begin() error {
+	return nil
+}
+
+func (nw *NginxWriter) WriteCIDR(cidr parser.CIDRInfo) error {
+	directive := POLICY_ALLOW
+	if nw.opts.Policy == POLICY_ALLOW {
+		directive = POLICY_DENY
+	}
+	comment := ""
+	if cidr.Label != "" {
+		comment = " # " + cidr.Label
+	}
+	_, err := fpf(nw.w, "%s %s;%s\n", directive, cidr.Prefix(), comment)
+	return err
+}
+
+func (nw *NginxWriter) End() error {
+	_, err := fpf(nw.w, "%s all;\n", nw.opts.Policy)
+	return err
+}
+
+// ApacheWriter writes an Apache 2.4 authorization block of Require ip
+// directives. The allow-list is a RequireAny block and the deny-list a
+// RequireAll block which grants all other addresses.
+type ApacheWriter struct {
+	w    io.Writer
+	opts Options
+}
+
+func (aw *ApacheWriter) Begin() error {
+	if aw.opts.Policy == POLICY_DENY {
+		_, err := fpf(aw.w, "<RequireAny>\n")
+		return err
+	}
+	_, err := fpf(aw.w, "<RequireAll>\n    Require all granted\n")
+	return err
+}
+
+func (aw *ApacheWriter) WriteCIDR(cidr parser.CIDRInfo) error {
+	p := &printer{w: aw.w}
+	if cidr.Label != "" {
+		p.printf("    # %s\n", cidr.Label)
+	}
+	if aw.opts.Policy == POLICY_DENY {
+		p.printf("    Require ip %s\n", cidr.Prefix())
+	} else {
+		p.printf("    Require not ip %s\n", cidr.Prefix())
+	}
+	return p.err
+}
+
+func (aw *ApacheWriter) End() error {
+	if aw.opts.Policy == POLICY_DENY {
+		_, err := fpf(aw.w, "</RequireAny>\n")
+		return err
+	}
+	_, err := fpf(aw.w, "</RequireAll>\n")
+	return err
+}
+
+// HAProxyWriter writes an HAProxy ACL pattern file which has a network on
+// each line. The header comment shows the rule which loads the file as
+// Options.Name + ".acl".
+type HAProxyWriter struct {
+	w    io.Writer
+	opts Options
+}
+
+func (hw *HAProxyWriter) Begin() error {
+	cond := "unless"
+	if hw.opts.Policy == POLICY_ALLOW {
+		cond = "if"
+	}
+	_, err := fpf(hw.w, "# http-request deny %s { src -f %s.acl }\n", cond, hw.opts.Name)
+	return err
+}
+
+func (hw *HAProxyWriter) WriteCIDR(cidr parser.CIDRInfo) error {
+	p := &printer{w: hw.w}
+	if cidr.Label != "" {
+		p.printf("# %s\n", cidr.Label)
+	}
+	p.printf("%s\n", cidr.Prefix())
+	return p.err
+}
+
+func (hw *HAProxyWriter) End() error {
+	return nil
+}
+
+// EnvoyWriter writes the rules of an Envoy RBAC filter which has a policy
+// named Options.Name whose principals are the records.
+type EnvoyWriter struct {
+	w     io.Writer
+	opts  Options
+	cidrs []parser.CIDRInfo
+}
+
+func (ew *EnvoyWriter) Begin() error {
+	return nil
+}
+
+func (ew *EnvoyWriter) WriteCIDR(cidr parser.CIDRInfo) error {
+	ew.cidrs = append(ew.cidrs, cidr)
+	return nil
+}
+
+func (ew *EnvoyWriter) End() error {
+	action := "ALLOW"
+	if ew.opts.Policy == POLICY_ALLOW {
+		action = "DENY"
+	}
+
+	p := &printer{w: ew.w}
+	p.printf("rules:\n")
+	p.printf("  action: %s\n", action)
+	p.printf("  policies:\n")
+	p.printf("    %s:\n", ew.opts.Name)
+	p.printf("      permissions:\n")
+	p.printf("      - any: true\n")
+	if len(ew.cidrs) == 0 {
+		p.printf("      principals: []\n")
+		return p.err
+	}
+	p.printf("      principals:\n")
+	for _, c := range ew.cidrs {
+		if c.Label != "" {
+			p.printf("      # %s\n", c.Label)
+		}
+		p.printf("      - remote_ip:\n")
+		p.printf("          address_prefix: %s\n", c.NetworkAddr())
+		p.printf("          prefix_len: %d\n", c.Bits())
+	}
+	return p.err
+}
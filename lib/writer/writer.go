@@ -2,91 +2,401 @@ package writer
 
 import (
 	"bytes"
+	"encoding/csv"
 	"fmt"
 	"io"
+	"net"
 	"os"
-	"strconv"
 	"strings"
 
+	"github.com/goldeneggg/ipcl/lib/cidrlist"
 	"github.com/goldeneggg/ipcl/lib/parser"
 )
 
+// Output formats.
+const (
+	FORMAT_DEFAULT = "default"
+	FORMAT_CSV     = "csv"
+	FORMAT_TSV     = "tsv"
+	FORMAT_JSON    = "json"
+	FORMAT_BIND    = "bind"
+
+	FORMAT_IPTABLES = "iptables"
+	FORMAT_NFTABLES = "nftables"
+	FORMAT_PF       = "pf"
+	FORMAT_CISCO    = "cisco"
+
+	FORMAT_TERRAFORM  = "terraform"
+	FORMAT_AWS_JSON   = "aws-json"
+	FORMAT_AWS_YAML   = "aws-yaml"
+	FORMAT_KUBERNETES = "k8s"
+
+	FORMAT_NGINX   = "nginx"
+	FORMAT_APACHE  = "apache"
+	FORMAT_HAPROXY = "haproxy"
+	FORMAT_ENVOY   = "envoy"
+
+	FORMAT_DHCPD = "dhcpd"
+	FORMAT_KEA   = "kea"
+
+	FORMAT_UNIFIED = "unified" // diff only
+)
+
+// Options configures the formats which need more than the records.
+// Zero fields are set to the defaults by NewFormatWriter.
+type Options struct {
+	NameServer  string // name server of bind zones, default "ns1.example.com."
+	Hostmaster  string // SOA mailbox of bind zones, default "hostmaster.example.com."
+	Domain      string // domain of PTR names in bind zones, default "example.com."
+	TTL         int    // TTL of bind zones, default 3600
+	Action      string // action of firewall rules, default ACTION_ACCEPT
+	Direction   string // direction of firewall rules, default DIRECTION_IN
+	Port        int    // tcp port of firewall rules, 0 matches any protocol
+	Name        string // name of generated tables, sets and lists, default "ipcl"
+	Policy      string // default policy of allow-lists, default POLICY_DENY
+	Router      string // router of DHCP scopes, default the first usable address
+	ReserveLow  int    // addresses reserved at the start of DHCP pools
+	ReserveHigh int    // addresses reserved at the end of DHCP pools
+	ReverseZone bool   // add reverse_zone to default, csv and tsv records
+}
+
+func (o *Options) setDefaults() {
+	if o.NameServer == "" {
+		o.NameServer = "ns1.example.com."
+	}
+	if o.Hostmaster == "" {
+		o.Hostmaster = "hostmaster.example.com."
+	}
+	if o.Domain == "" {
+		o.Domain = "example.com."
+	}
+	if o.TTL == 0 {
+		o.TTL = 3600
+	}
+	if o.Name == "" {
+		o.Name = "ipcl"
+	}
+	if o.Policy == "" {
+		o.Policy = POLICY_DENY
+	}
+	if o.Action == "" {
+		o.Action = ACTION_ACCEPT
+	}
+	if o.Direction == "" {
+		o.Direction = DIRECTION_IN
+	}
+}
+
 var (
 	Out     io.Writer = os.Stdout
 	fpf               = fmt.Fprintf
-	headers           = []string{"source_cidr",
-		"network",
-		"mask",
-		"host_num",
-		"min_address",
-		"max_address",
-		"broadcast"}
+	headers           = append(parser.Columns(), "label", "attributes")
 )
 
+// reverseZoneColumn is the index of reverse_zone in headers.
+const reverseZoneColumn = 7
+
+// Writer writes CIDRInfo records as a stream.
+//
+// Begin is called once before the first record and End once after the last
+// record. End writes the footer of the format, if any. Every step returns
+// the first error reported by the underlying io.Writer.
 type Writer interface {
-	Write(cidrs []parser.CIDRInfo)
+	Begin() error
+	WriteCIDR(cidr parser.CIDRInfo) error
+	End() error
+}
+
+// GroupWriter is a Writer which can write groups of records with
+// subtotals. BeginGroup and EndGroup are called around the records of each
+// group between Begin and End.
+type GroupWriter interface {
+	Writer
+	BeginGroup(g cidrlist.Group) error
+	EndGroup(g cidrlist.Group) error
+}
+
+// SummaryWriter is a Writer which writes a summary as the footer in End.
+type SummaryWriter interface {
+	Writer
+	SetSummary(s cidrlist.Summary)
 }
 
 type DefaultWriter struct {
-	w io.Writer
+	w           io.Writer
+	summary     *cidrlist.Summary
+	reverseZone bool
 }
 
+// SepWriter writes records as csv or tsv rows. It is not a SummaryWriter,
+// because a summary is not a row.
 type SepWriter struct {
-	*DefaultWriter
-	sep string
+	w           io.Writer
+	sep         string
+	reverseZone bool
 }
 
-func (dw *DefaultWriter) Write(cidrs []parser.CIDRInfo) {
+// WriteAll writes all cidrs to w between Begin and End.
+func WriteAll(w Writer, cidrs []parser.CIDRInfo) error {
+	if err := w.Begin(); err != nil {
+		return err
+	}
 	for _, cidr := range cidrs {
-		dw.writeSingle(cidr)
+		if err := w.WriteCIDR(cidr); err != nil {
+			return err
+		}
+	}
+
+	return w.End()
+}
+
+// WriteGroups writes groups to w between Begin and End. If w is not a
+// GroupWriter, only the records are written.
+func WriteGroups(w Writer, groups []cidrlist.Group) error {
+	if err := w.Begin(); err != nil {
+		return err
+	}
+
+	gw, isGroupWriter := w.(GroupWriter)
+	for _, g := range groups {
+		if isGroupWriter {
+			if err := gw.BeginGroup(g); err != nil {
+				return err
+			}
+		}
+		for _, cidr := range g.CIDRs {
+			if err := w.WriteCIDR(cidr); err != nil {
+				return err
+			}
+		}
+		if isGroupWriter {
+			if err := gw.EndGroup(g); err != nil {
+				return err
+			}
+		}
 	}
+
+	return w.End()
 }
 
-func (dw *DefaultWriter) writeSingle(cidr parser.CIDRInfo) {
-	fpf(dw.w, "%s : %s\n", headers[0], cidr.SrcCIDR)
-	fpf(dw.w, "%s     : %s\n", headers[1], cidr.Network)
-	fpf(dw.w, "%s        : %s\n", headers[2], mask2string(cidr.Mask))
-	fpf(dw.w, "%s    : %d\n", headers[3], cidr.HostNum)
-	fpf(dw.w, "%s : %s\n", headers[4], cidr.Min)
-	fpf(dw.w, "%s : %s\n", headers[5], cidr.Max)
-	fpf(dw.w, "%s   : %s\n", headers[6], cidr.Broadcast)
-	fpf(dw.w, "\n")
+func (dw *DefaultWriter) Begin() error {
+	return nil
 }
 
-func (sw *SepWriter) Write(cidrs []parser.CIDRInfo) {
-	sw.writeHeader()
-	for _, cidr := range cidrs {
-		sw.writeLine(cidr)
+func (dw *DefaultWriter) WriteCIDR(cidr parser.CIDRInfo) error {
+	// keys are aligned to the longest one
+	width := len(headers[0])
+	if dw.reverseZone {
+		width = len(headers[reverseZoneColumn])
+	}
+
+	p := &printer{w: dw.w}
+	p.printf("%-*s : %s\n", width, headers[0], cidr.SrcCIDR)
+	p.printf("%-*s : %s\n", width, headers[1], cidr.Network)
+	p.printf("%-*s : %s\n", width, headers[2], mask2string(cidr.Mask))
+	p.printf("%-*s : %s\n", width, headers[3], cidr.HostCount())
+	p.printf("%-*s : %s\n", width, headers[4], ipOr(cidr.Min, "-"))
+	p.printf("%-*s : %s\n", width, headers[5], ipOr(cidr.Max, "-"))
+	p.printf("%-*s : %s\n", width, headers[6], ipOr(cidr.Broadcast, "-"))
+	if dw.reverseZone {
+		p.printf("%-*s : %s\n", width, headers[7], strings.Join(cidr.ReverseZones(), " "))
+	}
+	if cidr.Label != "" {
+		p.printf("%-*s : %s\n", width, headers[8], cidr.Label)
 	}
+	if len(cidr.Attrs) > 0 {
+		p.printf("%-*s : %s\n", width, headers[9], cidr.AttrsString())
+	}
+	p.printf("\n")
+
+	return p.err
 }
 
-func (sw *SepWriter) writeHeader() {
-	fpf(sw.w, "%s\n", strings.Join(headers, sw.sep))
+func (dw *DefaultWriter) End() error {
+	if dw.summary == nil {
+		return nil
+	}
+
+	s := dw.summary
+	p := &printer{w: dw.w}
+	p.printf("# summary\n\n")
+	p.printf("inputs      : %d\n", s.Inputs)
+	p.printf("valid       : %d\n", s.Count)
+	p.printf("rejected    : %d\n", s.Rejected)
+	p.printf("covered     : %s addresses\n", s.Covered)
+	if s.Count > 0 {
+		p.printf("largest     : %s\n", s.Largest)
+		p.printf("smallest    : %s\n", s.Smallest)
+	}
+	for _, f := range s.Families {
+		p.printf("%-11s : %d cidrs, %s addresses, largest %s, smallest %s\n", f.Family, f.Count, f.Covered, f.Largest, f.Smallest)
+	}
+	p.printf("\n")
+
+	return p.err
+}
+
+// SetSummary sets the summary written by End.
+func (dw *DefaultWriter) SetSummary(s cidrlist.Summary) {
+	dw.summary = &s
+}
+
+func (dw *DefaultWriter) BeginGroup(g cidrlist.Group) error {
+	_, err := fpf(dw.w, "# %s\n\n", g.Name)
+	return err
+}
+
+func (dw *DefaultWriter) EndGroup(g cidrlist.Group) error {
+	_, err := fpf(dw.w, "%s    : %d cidrs, %s hosts\n\n", "subtotal", len(g.CIDRs), g.HostCount())
+	return err
+}
+
+func (sw *SepWriter) Begin() error {
+	return writeRow(sw.w, sw.sep, sw.row(headers))
+}
+
+// End writes nothing, because csv and tsv have no footer.
+func (sw *SepWriter) End() error {
+	return nil
+}
+
+func (sw *SepWriter) BeginGroup(g cidrlist.Group) error {
+	return nil
 }
 
-func (sw *SepWriter) writeLine(cidr parser.CIDRInfo) {
+// EndGroup writes nothing, because a subtotal row is not a CIDR row.
+func (sw *SepWriter) EndGroup(g cidrlist.Group) error {
+	return nil
+}
+
+func (sw *SepWriter) WriteCIDR(cidr parser.CIDRInfo) error {
 	s := []string{cidr.SrcCIDR,
 		cidr.Network.String(),
 		mask2string(cidr.Mask),
-		strconv.Itoa(cidr.HostNum),
-		cidr.Min.String(),
-		cidr.Max.String(),
-		cidr.Broadcast.String()}
-	fpf(sw.w, "%s\n", strings.Join(s, sw.sep))
+		cidr.HostCount().String(),
+		ipOr(cidr.Min, ""),
+		ipOr(cidr.Max, ""),
+		ipOr(cidr.Broadcast, ""),
+		strings.Join(cidr.ReverseZones(), " "),
+		cidr.Label,
+		cidr.AttrsString()}
+	return writeRow(sw.w, sw.sep, sw.row(s))
+}
+
+// row returns the columns of s to write, without reverse_zone unless it is
+// enabled.
+func (sw *SepWriter) row(s []string) []string {
+	if sw.reverseZone {
+		return s
+	}
+	return append(append([]string(nil), s[:reverseZoneColumn]...), s[reverseZoneColumn+1:]...)
+}
+
+// writeRow writes a csv row separated by sep. Fields which have sep, quotes
+// or newlines are quoted.
+func writeRow(w io.Writer, sep string, row []string) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = rune(sep[0])
+	if err := cw.Write(row); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// NewFormatWriter returns the Writer of format which writes to Out.
+func NewFormatWriter(format string, opts Options) (Writer, error) {
+	opts.setDefaults()
+
+	switch format {
+	case FORMAT_DEFAULT, "":
+		return &DefaultWriter{w: Out, reverseZone: opts.ReverseZone}, nil
+	case FORMAT_CSV:
+		return &SepWriter{Out, ",", opts.ReverseZone}, nil
+	case FORMAT_TSV:
+		return &SepWriter{Out, "\t", opts.ReverseZone}, nil
+	case FORMAT_JSON:
+		return &JSONWriter{w: Out}, nil
+	case FORMAT_BIND:
+		return &BindWriter{w: Out, opts: opts}, nil
+	case FORMAT_IPTABLES, FORMAT_NFTABLES, FORMAT_PF, FORMAT_CISCO:
+		if err := opts.validateFirewall(); err != nil {
+			return nil, err
+		}
+		return newFirewallWriter(format, opts), nil
+	case FORMAT_TERRAFORM:
+		return &TerraformWriter{w: Out, opts: opts}, nil
+	case FORMAT_AWS_JSON, FORMAT_AWS_YAML:
+		if err := opts.validateFirewall(); err != nil {
+			return nil, err
+		}
+		if opts.Action != ACTION_ACCEPT {
+			return nil, fmt.Errorf("action %s is not supported by security groups\n", opts.Action)
+		}
+		return &AWSWriter{w: Out, opts: opts, yaml: format == FORMAT_AWS_YAML}, nil
+	case FORMAT_KUBERNETES:
+		return &KubernetesWriter{w: Out}, nil
+	case FORMAT_NGINX, FORMAT_APACHE, FORMAT_HAPROXY, FORMAT_ENVOY:
+		if err := opts.validatePolicy(); err != nil {
+			return nil, err
+		}
+		return newWebWriter(format, opts), nil
+	case FORMAT_DHCPD, FORMAT_KEA:
+		if err := opts.validateDHCP(); err != nil {
+			return nil, err
+		}
+		if format == FORMAT_DHCPD {
+			return &DhcpdWriter{w: Out, opts: opts}, nil
+		}
+		return &KeaWriter{w: Out, opts: opts}, nil
+	}
+	return nil, fmt.Errorf("output format %s is not supported\n", format)
+}
+
+// Header returns the column names of the csv and tsv formats. reverse_zone
+// is added by Options.ReverseZone.
+func Header() []string {
+	return (&SepWriter{}).row(headers)
 }
 
 func NewWriter(isCsv bool, isTsv bool) Writer {
-	defWriter := &DefaultWriter{Out}
 	if isCsv {
-		return &SepWriter{defWriter, ","}
+		return &SepWriter{w: Out, sep: ","}
 	} else if isTsv {
-		return &SepWriter{defWriter, "\t"}
+		return &SepWriter{w: Out, sep: "\t"}
 	} else {
-		return defWriter
+		return &DefaultWriter{w: Out}
+	}
+}
+
+// printer keeps the first write error so that multi-line records can be
+// written without checking every line.
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) printf(format string, a ...interface{}) {
+	if p.err != nil {
+		return
 	}
+	_, p.err = fpf(p.w, format, a...)
+}
+
+// ipOr returns ip as a string, or none for a nil ip such as the broadcast
+// address of IPv6.
+func ipOr(ip net.IP, none string) string {
+	if ip == nil {
+		return none
+	}
+	return ip.String()
 }
 
 func mask2string(mask []byte) string {
+	if len(mask) == net.IPv6len {
+		return net.IP(mask).String()
+	}
+
 	var buf bytes.Buffer
 	for i, m := range mask {
 		buf.WriteString(itod(uint(m)))
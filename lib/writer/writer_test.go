@@ -2,6 +2,13 @@ package writer
 
 import (
 	//"fmt"
+	"bytes"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/goldeneggg/ipcl/lib/cidrlist"
 	"github.com/goldeneggg/ipcl/lib/parser"
 )
 
@@ -27,7 +34,7 @@ var vals = []struct {
 		board_cast:  "broadcast   : 192.168.56.255"}},
 }
 
-func ExampleWriter_Write() {
+func ExampleWriteAll() {
 	writer := NewWriter(false, false)
 
 	cis := make([]parser.CIDRInfo, len(vals))
@@ -36,7 +43,7 @@ func ExampleWriter_Write() {
 		cis = append(cis, ci)
 	}
 
-	writer.Write(cis)
+	WriteAll(writer, cis)
 	//	// Output:
 	//	// source_cidr : 192.168.56.0/24
 	//	// network     : 192.168.56.0
@@ -46,3 +53,1011 @@ func ExampleWriter_Write() {
 	//	// max_address : 192.168.56.254
 	//	// broadcast   : 192.168.56.255
 }
+
+type errWriter struct {
+	err error
+}
+
+func (ew *errWriter) Write(p []byte) (int, error) {
+	return 0, ew.err
+}
+
+func TestWriteAllOutError(t *testing.T) {
+	orgOut := Out
+	defer func() { Out = orgOut }()
+
+	ci, _ := parser.Parse("192.168.1.0/24")
+	expected := errors.New("closed")
+	Out = &errWriter{expected}
+
+	for _, fmts := range [][]bool{{false, false}, {true, false}, {false, true}} {
+		w := NewWriter(fmts[0], fmts[1])
+		if e := WriteAll(w, []parser.CIDRInfo{ci}); e != expected {
+			t.Errorf("WriteAll(%T) error, actual: %v, expected: %v", w, e, expected)
+		}
+	}
+}
+
+func TestWriteAllLabel(t *testing.T) {
+	orgOut := Out
+	defer func() { Out = orgOut }()
+
+	labelled, _ := parser.ParseLine("10.20.0.0/16 prod-vpc owner=netops")
+	plain, _ := parser.ParseLine("192.168.1.0/24")
+
+	var buf bytes.Buffer
+	Out = &buf
+	WriteAll(NewWriter(true, false), []parser.CIDRInfo{labelled, plain})
+	expected := `source_cidr,network,mask,host_num,min_address,max_address,broadcast,label,attributes
+10.20.0.0/16,10.20.0.0,255.255.0.0,65534,10.20.0.1,10.20.255.254,10.20.255.255,prod-vpc,owner=netops
+192.168.1.0/24,192.168.1.0,255.255.255.0,254,192.168.1.1,192.168.1.254,192.168.1.255,,
+`
+	if buf.String() != expected {
+		t.Errorf("csv actual:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	buf.Reset()
+	WriteAll(NewWriter(false, false), []parser.CIDRInfo{labelled})
+	if !strings.Contains(buf.String(), "broadcast   : 10.20.255.255\nlabel       : prod-vpc\nattributes  : owner=netops\n") {
+		t.Errorf("default actual:\n%s", buf.String())
+	}
+}
+
+func TestWriteReverseZone(t *testing.T) {
+	orgOut := Out
+	defer func() { Out = orgOut }()
+
+	ci, _ := parser.ParseLine("10.20.0.0/16 prod-vpc")
+	cases := []struct {
+		format   string
+		expected string
+	}{
+		{FORMAT_DEFAULT, `source_cidr  : 10.20.0.0/16
+network      : 10.20.0.0
+mask         : 255.255.0.0
+host_num     : 65534
+min_address  : 10.20.0.1
+max_address  : 10.20.255.254
+broadcast    : 10.20.255.255
+reverse_zone : 20.10.in-addr.arpa
+label        : prod-vpc
+
+`},
+		{FORMAT_CSV, `source_cidr,network,mask,host_num,min_address,max_address,broadcast,reverse_zone,label,attributes
+10.20.0.0/16,10.20.0.0,255.255.0.0,65534,10.20.0.1,10.20.255.254,10.20.255.255,20.10.in-addr.arpa,prod-vpc,
+`},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		Out = &buf
+		w, _ := NewFormatWriter(c.format, Options{ReverseZone: true})
+		WriteAll(w, []parser.CIDRInfo{ci})
+		if buf.String() != c.expected {
+			t.Errorf("%s actual:\n%s\nexpected:\n%s", c.format, buf.String(), c.expected)
+		}
+	}
+}
+
+func TestWriteGroups(t *testing.T) {
+	orgOut := Out
+	defer func() { Out = orgOut }()
+
+	var cidrs []parser.CIDRInfo
+	for _, s := range []string{"10.0.0.0/24", "192.168.1.0/30", "10.1.0.0/24"} {
+		ci, _ := parser.Parse(s)
+		cidrs = append(cidrs, ci)
+	}
+	groups, _ := cidrlist.GroupBy(cidrs, cidrlist.GROUP_CLASS)
+
+	var buf bytes.Buffer
+	Out = &buf
+	WriteGroups(NewWriter(true, false), groups)
+	expected := `source_cidr,network,mask,host_num,min_address,max_address,broadcast,label,attributes
+10.0.0.0/24,10.0.0.0,255.255.255.0,254,10.0.0.1,10.0.0.254,10.0.0.255,,
+10.1.0.0/24,10.1.0.0,255.255.255.0,254,10.1.0.1,10.1.0.254,10.1.0.255,,
+192.168.1.0/30,192.168.1.0,255.255.255.252,2,192.168.1.1,192.168.1.2,192.168.1.3,,
+`
+	if buf.String() != expected {
+		t.Errorf("csv actual:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	buf.Reset()
+	WriteGroups(NewWriter(false, false), groups)
+	if !strings.HasPrefix(buf.String(), "# class A\n\n") || !strings.HasSuffix(buf.String(), "subtotal    : 1 cidrs, 2 hosts\n\n") {
+		t.Errorf("default actual:\n%s", buf.String())
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	orgOut := Out
+	defer func() { Out = orgOut }()
+
+	var cidrs []parser.CIDRInfo
+	for _, s := range []string{"10.0.0.0/24", "10.0.0.0/25", "192.168.1.1/32", "2001:db8::/126"} {
+		ci, _ := parser.ParseLine(s)
+		cidrs = append(cidrs, ci)
+	}
+	cidrs[0].Label = "prod"
+	summary := cidrlist.Summarize(cidrs, 1)
+
+	var buf bytes.Buffer
+	Out = &buf
+	w, _ := NewFormatWriter(FORMAT_JSON, Options{})
+	w.(SummaryWriter).SetSummary(summary)
+	WriteAll(w, cidrs[2:])
+	expected := `[
+  {"source_cidr":"192.168.1.1/32","network":"192.168.1.1","mask":"255.255.255.255","host_num":1,"min_address":null,"max_address":null,"broadcast":null,"reverse_zone":["1.168.192.in-addr.arpa"]},
+  {"source_cidr":"2001:db8::/126","network":"2001:db8::","mask":"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffc","host_num":4,"min_address":"2001:db8::","max_address":"2001:db8::3","broadcast":null,"reverse_zone":["0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"]},
+  {"summary":{"inputs":5,"rejected":1,"cidrs":4,"covered":261,"largest":"10.0.0.0/24","smallest":"192.168.1.1/32","families":[{"family":"ipv4","cidrs":3,"covered":257,"largest":"10.0.0.0/24","smallest":"192.168.1.1/32"},{"family":"ipv6","cidrs":1,"covered":4,"largest":"2001:db8::/126","smallest":"2001:db8::/126"}]}}
+]
+`
+	if buf.String() != expected {
+		t.Errorf("json actual:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	buf.Reset()
+	w, _ = NewFormatWriter(FORMAT_DEFAULT, Options{})
+	w.(SummaryWriter).SetSummary(summary)
+	WriteAll(w, nil)
+	expected = `# summary
+
+inputs      : 5
+valid       : 4
+rejected    : 1
+covered     : 261 addresses
+largest     : 10.0.0.0/24
+smallest    : 192.168.1.1/32
+ipv4        : 3 cidrs, 257 addresses, largest 10.0.0.0/24, smallest 192.168.1.1/32
+ipv6        : 1 cidrs, 4 addresses, largest 2001:db8::/126, smallest 2001:db8::/126
+
+`
+	if buf.String() != expected {
+		t.Errorf("default actual:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	for _, format := range []string{FORMAT_CSV, FORMAT_TSV, FORMAT_IPTABLES} {
+		w, _ = NewFormatWriter(format, Options{})
+		if _, ok := w.(SummaryWriter); ok {
+			t.Errorf("%s writer is a SummaryWriter", format)
+		}
+	}
+
+	if _, e := NewFormatWriter("xml", Options{}); e == nil {
+		t.Errorf("NewFormatWriter(xml) expected error")
+	}
+}
+
+func TestBindWriter(t *testing.T) {
+	orgOut := Out
+	defer func() { Out = orgOut }()
+
+	var buf bytes.Buffer
+	Out = &buf
+	ci, _ := parser.Parse("192.168.1.64/30")
+	w, _ := NewFormatWriter(FORMAT_BIND, Options{NameServer: "ns.example.net."})
+	WriteAll(w, []parser.CIDRInfo{ci})
+	expected := `; 192.168.1.64/30
+; RFC 2317 delegation in the parent zone 1.168.192.in-addr.arpa.:
+;   64/30	IN	NS	ns.example.net.
+;   $GENERATE 64-67 $ IN CNAME $.64/30.1.168.192.in-addr.arpa.
+$ORIGIN 64/30.1.168.192.in-addr.arpa.
+$TTL 3600
+@	IN	SOA	ns.example.net. hostmaster.example.com. (
+			1	; serial
+			3600	; refresh
+			900	; retry
+			604800	; expire
+			3600 )	; negative cache ttl
+@	IN	NS	ns.example.net.
+;64	IN	PTR	host-192-168-1-64.example.com.
+;65	IN	PTR	host-192-168-1-65.example.com.
+;66	IN	PTR	host-192-168-1-66.example.com.
+;67	IN	PTR	host-192-168-1-67.example.com.
+
+`
+	if buf.String() != expected {
+		t.Errorf("bind actual:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	buf.Reset()
+	ci, _ = parser.Parse("10.0.0.0/15")
+	WriteAll(w, []parser.CIDRInfo{ci})
+	for _, s := range []string{"$ORIGIN 0.10.in-addr.arpa.\n", "$ORIGIN 1.10.in-addr.arpa.\n", ";0.0\tIN\tPTR\thost-10-0-0-0.example.com.\n", ";0.0\tIN\tPTR\thost-10-1-0-0.example.com.\n"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("bind actual:\n%s\nnot contains: %s", buf.String(), s)
+		}
+	}
+
+	buf.Reset()
+	ci, _ = parser.Parse("2001:db8::/126")
+	WriteAll(w, []parser.CIDRInfo{ci})
+	for _, s := range []string{"$ORIGIN 0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.\n", ";3\tIN\tPTR\thost-2001-db8--3.example.com.\n"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("bind actual:\n%s\nnot contains: %s", buf.String(), s)
+		}
+	}
+}
+
+func TestFirewallWriters(t *testing.T) {
+	orgOut := Out
+	defer func() { Out = orgOut }()
+
+	var cidrs []parser.CIDRInfo
+	for _, s := range []string{"10.0.0.0/8 office", "192.168.1.1/32", "2001:db8::/32"} {
+		ci, _ := parser.ParseLine(s)
+		cidrs = append(cidrs, ci)
+	}
+
+	cases := []struct {
+		format   string
+		opts     Options
+		expected string
+	}{
+		{
+			format: FORMAT_IPTABLES,
+			opts:   Options{Action: ACTION_DROP, Port: 22},
+			expected: `iptables -A INPUT -s 10.0.0.0/8 -p tcp --dport 22 -m comment --comment 'office' -j DROP
+iptables -A INPUT -s 192.168.1.1/32 -p tcp --dport 22 -j DROP
+ip6tables -A INPUT -s 2001:db8::/32 -p tcp --dport 22 -j DROP
+`,
+		},
+		{
+			format: FORMAT_NFTABLES,
+			opts:   Options{Direction: DIRECTION_OUT},
+			expected: `table inet ipcl {
+	set ipcl_v4 {
+		type ipv4_addr
+		flags interval
+		elements = { 10.0.0.0/8, 192.168.1.1/32 }
+	}
+
+	set ipcl_v6 {
+		type ipv6_addr
+		flags interval
+		elements = { 2001:db8::/32 }
+	}
+
+	chain output {
+		type filter hook output priority 0;
+		ip daddr @ipcl_v4 accept
+		ip6 daddr @ipcl_v6 accept
+	}
+}
+`,
+		},
+		{
+			format: FORMAT_PF,
+			opts:   Options{Action: ACTION_DROP, Port: 443},
+			expected: `table <ipcl> { 10.0.0.0/8, 192.168.1.1/32, 2001:db8::/32 }
+block in quick proto tcp from <ipcl> to any port 443
+`,
+		},
+		{
+			format: FORMAT_CISCO,
+			opts:   Options{Direction: DIRECTION_OUT},
+			expected: `ip access-list extended ipcl
+ remark office
+ permit ip any 10.0.0.0 0.255.255.255
+ permit ip any host 192.168.1.1
+ipv6 access-list ipcl
+ permit ipv6 any 2001:db8::/32
+`,
+		},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		Out = &buf
+		w, err := NewFormatWriter(c.format, c.opts)
+		if err != nil {
+			t.Errorf("%s error: %#v", c.format, err)
+			continue
+		}
+		if err := WriteAll(w, cidrs); err != nil {
+			t.Errorf("%s write error: %#v", c.format, err)
+		}
+		if buf.String() != c.expected {
+			t.Errorf("%s actual:\n%s\nexpected:\n%s", c.format, buf.String(), c.expected)
+		}
+	}
+
+	var buf bytes.Buffer
+	Out = &buf
+	evil, _ := parser.ParseLine("10.0.0.0/8 $(rm -rf ~) `id` it's")
+	w, _ := NewFormatWriter(FORMAT_IPTABLES, Options{})
+	WriteAll(w, []parser.CIDRInfo{evil})
+	expected := `iptables -A INPUT -s 10.0.0.0/8 -m comment --comment '$(rm -rf ~) ` + "`id`" + ` it'\''s' -j ACCEPT
+`
+	if buf.String() != expected {
+		t.Errorf("iptables quoted actual:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	buf.Reset()
+	var overlaps []parser.CIDRInfo
+	for _, s := range []string{"10.0.1.0/24", "10.0.0.0/16", "10.0.0.0/16", "10.1.0.0/16", "2001:db8::/33", "2001:db8:8000::/33"} {
+		ci, _ := parser.Parse(s)
+		overlaps = append(overlaps, ci)
+	}
+	w, _ = NewFormatWriter(FORMAT_NFTABLES, Options{})
+	WriteAll(w, overlaps)
+	for _, s := range []string{"elements = { 10.0.0.0/15 }\n", "elements = { 2001:db8::/32 }\n"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("nftables merged actual:\n%s\nnot contains: %s", buf.String(), s)
+		}
+	}
+
+	for _, opts := range []Options{{Action: "reject"}, {Direction: "both"}, {Port: 65536}} {
+		if _, err := NewFormatWriter(FORMAT_IPTABLES, opts); err == nil {
+			t.Errorf("options %#v expected error", opts)
+		}
+	}
+}
+
+func TestIaCWriters(t *testing.T) {
+	orgOut := Out
+	defer func() { Out = orgOut }()
+
+	var cidrs []parser.CIDRInfo
+	for _, s := range []string{"10.0.0.0/8 office", "10.1.0.0/16 except=true", "2001:db8::/32"} {
+		ci, _ := parser.ParseLine(s)
+		cidrs = append(cidrs, ci)
+	}
+
+	cases := []struct {
+		format   string
+		opts     Options
+		expected string
+	}{
+		{
+			format: FORMAT_TERRAFORM,
+			opts:   Options{Name: "office_cidrs"},
+			expected: `locals {
+  office_cidrs = [
+    "10.0.0.0/8", # office
+    "10.1.0.0/16",
+    "2001:db8::/32",
+  ]
+}
+`,
+		},
+		{
+			format: FORMAT_AWS_JSON,
+			opts:   Options{Port: 443},
+			expected: `{
+  "SecurityGroupIngress": [
+    {
+      "IpProtocol": "tcp",
+      "FromPort": 443,
+      "ToPort": 443,
+      "CidrIp": "10.0.0.0/8",
+      "Description": "office"
+    },
+    {
+      "IpProtocol": "tcp",
+      "FromPort": 443,
+      "ToPort": 443,
+      "CidrIp": "10.1.0.0/16"
+    },
+    {
+      "IpProtocol": "tcp",
+      "FromPort": 443,
+      "ToPort": 443,
+      "CidrIpv6": "2001:db8::/32"
+    }
+  ]
+}
+`,
+		},
+		{
+			format: FORMAT_AWS_YAML,
+			opts:   Options{Direction: DIRECTION_OUT},
+			expected: `SecurityGroupEgress:
+  - IpProtocol: "-1"
+    CidrIp: 10.0.0.0/8
+    Description: "office"
+  - IpProtocol: "-1"
+    CidrIp: 10.1.0.0/16
+  - IpProtocol: "-1"
+    CidrIpv6: 2001:db8::/32
+`,
+		},
+		{
+			format: FORMAT_KUBERNETES,
+			expected: `# office
+- ipBlock:
+    cidr: 10.0.0.0/8
+    except:
+    - 10.1.0.0/16
+- ipBlock:
+    cidr: 2001:db8::/32
+`,
+		},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		Out = &buf
+		w, err := NewFormatWriter(c.format, c.opts)
+		if err != nil {
+			t.Errorf("%s error: %#v", c.format, err)
+			continue
+		}
+		if err := WriteAll(w, cidrs); err != nil {
+			t.Errorf("%s write error: %#v", c.format, err)
+		}
+		if buf.String() != c.expected {
+			t.Errorf("%s actual:\n%s\nexpected:\n%s", c.format, buf.String(), c.expected)
+		}
+	}
+
+	if _, err := NewFormatWriter(FORMAT_AWS_JSON, Options{Action: ACTION_DROP}); err == nil {
+		t.Errorf("aws drop action expected error")
+	}
+
+	Out = &bytes.Buffer{}
+	w, _ := NewFormatWriter(FORMAT_KUBERNETES, Options{})
+	orphan, _ := parser.ParseLine("192.168.0.0/24 except=true")
+	if err := WriteAll(w, append(cidrs, orphan)); err == nil {
+		t.Errorf("except out of any ipBlock expected error")
+	}
+}
+
+func TestWebWriters(t *testing.T) {
+	orgOut := Out
+	defer func() { Out = orgOut }()
+
+	var cidrs []parser.CIDRInfo
+	for _, s := range []string{"10.0.0.0/8 office", "2001:db8::/32"} {
+		ci, _ := parser.ParseLine(s)
+		cidrs = append(cidrs, ci)
+	}
+
+	cases := []struct {
+		format   string
+		opts     Options
+		expected string
+	}{
+		{
+			format: FORMAT_NGINX,
+			expected: `allow 10.0.0.0/8; # office
+allow 2001:db8::/32;
+deny all;
+`,
+		},
+		{
+			format: FORMAT_NGINX,
+			opts:   Options{Policy: POLICY_ALLOW},
+			expected: `deny 10.0.0.0/8; # office
+deny 2001:db8::/32;
+allow all;
+`,
+		},
+		{
+			format: FORMAT_APACHE,
+			expected: `<RequireAny>
+    # office
+    Require ip 10.0.0.0/8
+    Require ip 2001:db8::/32
+</RequireAny>
+`,
+		},
+		{
+			format: FORMAT_APACHE,
+			opts:   Options{Policy: POLICY_ALLOW},
+			expected: `<RequireAll>
+    Require all granted
+    # office
+    Require not ip 10.0.0.0/8
+    Require not ip 2001:db8::/32
+</RequireAll>
+`,
+		},
+		{
+			format: FORMAT_HAPROXY,
+			opts:   Options{Name: "office"},
+			expected: `# http-request deny unless { src -f office.acl }
+# office
+10.0.0.0/8
+2001:db8::/32
+`,
+		},
+		{
+			format: FORMAT_ENVOY,
+			opts:   Options{Policy: POLICY_ALLOW},
+			expected: `rules:
+  action: DENY
+  policies:
+    ipcl:
+      permissions:
+      - any: true
+      principals:
+      # office
+      - remote_ip:
+          address_prefix: 10.0.0.0
+          prefix_len: 8
+      - remote_ip:
+          address_prefix: 2001:db8::
+          prefix_len: 32
+`,
+		},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		Out = &buf
+		w, err := NewFormatWriter(c.format, c.opts)
+		if err != nil {
+			t.Errorf("%s error: %#v", c.format, err)
+			continue
+		}
+		if err := WriteAll(w, cidrs); err != nil {
+			t.Errorf("%s write error: %#v", c.format, err)
+		}
+		if buf.String() != c.expected {
+			t.Errorf("%s actual:\n%s\nexpected:\n%s", c.format, buf.String(), c.expected)
+		}
+	}
+
+	if _, err := NewFormatWriter(FORMAT_NGINX, Options{Policy: "reject"}); err == nil {
+		t.Errorf("unknown policy expected error")
+	}
+}
+
+func TestDHCPWriters(t *testing.T) {
+	orgOut := Out
+	defer func() { Out = orgOut }()
+
+	ci, _ := parser.ParseLine("192.168.1.0/24 office")
+	cases := []struct {
+		format   string
+		opts     Options
+		expected string
+	}{
+		{
+			format: FORMAT_DHCPD,
+			opts:   Options{ReserveLow: 9, ReserveHigh: 10},
+			expected: `subnet 192.168.1.0 netmask 255.255.255.0 {
+  # office
+  range 192.168.1.10 192.168.1.244;
+  option routers 192.168.1.1;
+  option subnet-mask 255.255.255.0;
+  option broadcast-address 192.168.1.255;
+}
+`,
+		},
+		{
+			format: FORMAT_DHCPD,
+			expected: `subnet 192.168.1.0 netmask 255.255.255.0 {
+  # office
+  range 192.168.1.2 192.168.1.254;
+  option routers 192.168.1.1;
+  option subnet-mask 255.255.255.0;
+  option broadcast-address 192.168.1.255;
+}
+`,
+		},
+		{
+			format: FORMAT_KEA,
+			opts:   Options{Router: "192.168.1.100"},
+			expected: `{
+  "subnet4": [
+    {
+      "id": 1,
+      "subnet": "192.168.1.0/24",
+      "pools": [
+        {
+          "pool": "192.168.1.1 - 192.168.1.99"
+        },
+        {
+          "pool": "192.168.1.101 - 192.168.1.254"
+        }
+      ],
+      "option-data": [
+        {
+          "name": "routers",
+          "data": "192.168.1.100"
+        },
+        {
+          "name": "subnet-mask",
+          "data": "255.255.255.0"
+        },
+        {
+          "name": "broadcast-address",
+          "data": "192.168.1.255"
+        }
+      ],
+      "user-context": {
+        "comment": "office"
+      }
+    }
+  ]
+}
+`,
+		},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		Out = &buf
+		w, err := NewFormatWriter(c.format, c.opts)
+		if err != nil {
+			t.Errorf("%s error: %#v", c.format, err)
+			continue
+		}
+		if err := WriteAll(w, []parser.CIDRInfo{ci}); err != nil {
+			t.Errorf("%s write error: %#v", c.format, err)
+		}
+		if buf.String() != c.expected {
+			t.Errorf("%s actual:\n%s\nexpected:\n%s", c.format, buf.String(), c.expected)
+		}
+	}
+
+	errCases := []struct {
+		cidr string
+		opts Options
+	}{
+		{"2001:db8::/64", Options{}},
+		{"10.0.0.0/31", Options{}},
+		{"10.0.0.0/30", Options{ReserveLow: 1, ReserveHigh: 1}},
+		{"10.0.0.0/24", Options{Router: "10.0.1.1"}},
+		{"10.0.0.0/24", Options{Router: "10.0.0.0"}},
+		{"10.0.0.0/24", Options{Router: "10.0.0.255"}},
+	}
+	for _, c := range errCases {
+		Out = &bytes.Buffer{}
+		w, _ := NewFormatWriter(FORMAT_DHCPD, c.opts)
+		ci, _ := parser.Parse(c.cidr)
+		if err := WriteAll(w, []parser.CIDRInfo{ci}); err == nil {
+			t.Errorf("%s %#v expected error", c.cidr, c.opts)
+		}
+	}
+
+	for _, opts := range []Options{{Router: "gateway"}, {Router: "2001:db8::1"}, {ReserveLow: -1}} {
+		if _, err := NewFormatWriter(FORMAT_KEA, opts); err == nil {
+			t.Errorf("options %#v expected error", opts)
+		}
+	}
+}
+
+func TestWriteUsage(t *testing.T) {
+	orgOut := Out
+	defer func() { Out = orgOut }()
+
+	parent, _ := parser.Parse("10.0.0.0/22")
+	var children []parser.CIDRInfo
+	for _, s := range []string{"10.0.1.0/24 web", "10.0.0.32/27"} {
+		ci, _ := parser.ParseLine(s)
+		children = append(children, ci)
+	}
+	usages := []cidrlist.Usage{cidrlist.UsageOf(parent, children)}
+
+	cases := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format: FORMAT_DEFAULT,
+			expected: `parent      : 10.0.0.0/22
+addresses   : 1024
+used        : 288 (28.12%)
+free        : 736 (71.88%)
+free blocks : 4
+largest free: 10.0.2.0-10.0.3.255 (512 addresses), 10.0.2.0/23
+child       : 10.0.0.32/27               32 addresses   3.12%
+child       : 10.0.1.0/24               256 addresses  25.00% web
+
+`,
+		},
+		{
+			format: FORMAT_CSV,
+			expected: `parent,cidr,label,addresses,used,free,used_percent,free_blocks,largest_free,largest_free_cidr
+10.0.0.0/22,10.0.0.0/22,,1024,288,736,28.12,4,10.0.2.0-10.0.3.255,10.0.2.0/23
+10.0.0.0/22,10.0.0.32/27,,32,32,,3.12,,,
+10.0.0.0/22,10.0.1.0/24,web,256,256,,25.00,,,
+`,
+		},
+		{
+			format: FORMAT_JSON,
+			expected: `[
+  {
+    "parent": "10.0.0.0/22",
+    "addresses": 1024,
+    "used": 288,
+    "free": 736,
+    "used_percent": 28.12,
+    "free_blocks": 4,
+    "largest_free": "10.0.2.0-10.0.3.255",
+    "largest_free_cidr": "10.0.2.0/23",
+    "children": [
+      {
+        "cidr": "10.0.0.32/27",
+        "addresses": 32,
+        "used_percent": 3.12
+      },
+      {
+        "cidr": "10.0.1.0/24",
+        "label": "web",
+        "addresses": 256,
+        "used_percent": 25
+      }
+    ]
+  }
+]
+`,
+		},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		Out = &buf
+		if err := WriteUsage(c.format, usages); err != nil {
+			t.Errorf("%s error: %#v", c.format, err)
+		}
+		if buf.String() != c.expected {
+			t.Errorf("%s actual:\n%s\nexpected:\n%s", c.format, buf.String(), c.expected)
+		}
+	}
+
+	if err := WriteUsage(FORMAT_BIND, usages); err == nil {
+		t.Errorf("bind usage expected error")
+	}
+}
+
+func TestWriteMap(t *testing.T) {
+	orgOut := Out
+	defer func() { Out = orgOut }()
+
+	parent, _ := parser.Parse("10.0.0.0/24")
+	var cidrs []parser.CIDRInfo
+	for _, s := range []string{"10.0.0.0/26 web", "10.0.0.64/28", "10.0.0.68/30", "10.0.0.128/28", "10.0.0.144/28", "192.168.0.0/24"} {
+		ci, _ := parser.ParseLine(s)
+		cidrs = append(cidrs, ci)
+	}
+
+	var buf bytes.Buffer
+	Out = &buf
+	if err := WriteMap(FORMAT_DEFAULT, parent, cidrs, 27, false); err != nil {
+		t.Fatalf("WriteMap error: %#v", err)
+	}
+	expected := `10.0.0.0/24, 8 cells of /27
+
+10.0.0.0   AA!.+...
+
+. free
+A 10.0.0.0/26 web
+B 10.0.0.64/28
+C 10.0.0.68/30
+D 10.0.0.128/28
+E 10.0.0.144/28
++ several allocations
+! overlapping allocations
+`
+	if buf.String() != expected {
+		t.Errorf("map actual:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	buf.Reset()
+	WriteMap(FORMAT_DEFAULT, parent, cidrs[:1], 25, true)
+	if !strings.Contains(buf.String(), "\x1b[32mA\x1b[0m.\n") {
+		t.Errorf("colored map actual:\n%q", buf.String())
+	}
+
+	if MapCellBits(parent) != 32 {
+		t.Errorf("MapCellBits actual: %d", MapCellBits(parent))
+	}
+	if err := WriteMap(FORMAT_DEFAULT, parent, cidrs, 23, false); err == nil {
+		t.Errorf("cells larger than parent expected error")
+	}
+	p6, _ := parser.Parse("2001:db8::/32")
+	if err := WriteMap(FORMAT_DEFAULT, p6, cidrs, 64, false); err == nil {
+		t.Errorf("too many cells expected error")
+	}
+	if err := WriteMap(FORMAT_CSV, parent, cidrs, 27, false); err == nil {
+		t.Errorf("csv map expected error")
+	}
+}
+
+func TestWriteLookup(t *testing.T) {
+	orgOut := Out
+	defer func() { Out = orgOut }()
+
+	results := []LookupResult{
+		{IP: "10.20.3.4", Prefix: netip.MustParsePrefix("10.20.0.0/16"), Label: "prod, vpc"},
+		{IP: "192.168.0.1"},
+	}
+	cases := []struct {
+		format   string
+		expected string
+	}{
+		{FORMAT_DEFAULT, "10.20.3.4\t10.20.0.0/16\tprod, vpc\n192.168.0.1\t\t\n"},
+		{FORMAT_CSV, "ip,prefix,label\n10.20.3.4,10.20.0.0/16,\"prod, vpc\"\n192.168.0.1,,\n"},
+		{FORMAT_JSON, `[
+  {
+    "ip": "10.20.3.4",
+    "prefix": "10.20.0.0/16",
+    "label": "prod, vpc"
+  },
+  {
+    "ip": "192.168.0.1",
+    "prefix": null
+  }
+]
+`},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		Out = &buf
+		if err := WriteLookup(c.format, results); err != nil {
+			t.Fatalf("%s WriteLookup error: %#v", c.format, err)
+		}
+		if buf.String() != c.expected {
+			t.Errorf("%s actual:\n%s\nexpected:\n%s", c.format, buf.String(), c.expected)
+		}
+	}
+
+	if err := WriteLookup(FORMAT_IPTABLES, results); err == nil {
+		t.Errorf("iptables lookup expected error")
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	orgOut := Out
+	defer func() { Out = orgOut }()
+
+	var cidrs []parser.CIDRInfo
+	for _, s := range []string{"10.0.0.0/16 corp", "10.0.0.0/20", "10.0.0.0/24 web", "10.0.1.0/24", "10.0.16.0/20", "192.168.0.0/24"} {
+		ci, _ := parser.ParseLine(s)
+		cidrs = append(cidrs, ci)
+	}
+	roots := cidrlist.Tree(cidrs)
+
+	var buf bytes.Buffer
+	Out = &buf
+	if err := WriteTree(FORMAT_DEFAULT, roots); err != nil {
+		t.Fatalf("WriteTree error: %#v", err)
+	}
+	expected := `10.0.0.0/16 corp (65534 hosts, 57344 unallocated)
+├── 10.0.0.0/20 (4094 hosts, 3584 unallocated)
+│   ├── 10.0.0.0/24 web (254 hosts)
+│   └── 10.0.1.0/24 (254 hosts)
+└── 10.0.16.0/20 (4094 hosts)
+192.168.0.0/24 (254 hosts)
+`
+	if buf.String() != expected {
+		t.Errorf("tree actual:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	buf.Reset()
+	if err := WriteTree(FORMAT_JSON, roots[1:]); err != nil {
+		t.Fatalf("WriteTree json error: %#v", err)
+	}
+	expected = `[
+  {
+    "cidr": "192.168.0.0/24",
+    "host_num": 254
+  }
+]
+`
+	if buf.String() != expected {
+		t.Errorf("tree json actual:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	if err := WriteTree(FORMAT_CSV, roots); err == nil {
+		t.Errorf("csv tree expected error")
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	orgOut := Out
+	defer func() { Out = orgOut }()
+
+	parse := func(ss ...string) []parser.CIDRInfo {
+		var cidrs []parser.CIDRInfo
+		for _, s := range ss {
+			ci, _ := parser.Parse(s)
+			cidrs = append(cidrs, ci)
+		}
+		return cidrs
+	}
+	d := cidrlist.DiffOf(parse("10.0.0.0/24", "10.0.1.0/24"), parse("10.0.0.0/25", "10.0.0.128/25", "10.0.2.0/24"))
+
+	cases := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format: FORMAT_DEFAULT,
+			expected: `added     : 10.0.2.0/24 (256 addresses)
+removed   : 10.0.1.0/24 (256 addresses)
+unchanged : 10.0.0.0/24 (256 addresses)
+total     : 256 added, 256 removed, 256 unchanged addresses
+`,
+		},
+		{
+			format: FORMAT_UNIFIED,
+			expected: `--- old.txt
++++ new.txt
+ 10.0.0.0/24
+-10.0.1.0/24
++10.0.2.0/24
+`,
+		},
+		{
+			format: FORMAT_JSON,
+			expected: `{
+  "old": "old.txt",
+  "new": "new.txt",
+  "changed": true,
+  "added": [
+    "10.0.2.0/24"
+  ],
+  "removed": [
+    "10.0.1.0/24"
+  ],
+  "unchanged": [
+    "10.0.0.0/24"
+  ],
+  "added_addresses": 256,
+  "removed_addresses": 256,
+  "unchanged_addresses": 256
+}
+`,
+		},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		Out = &buf
+		if err := WriteDiff(c.format, "old.txt", "new.txt", d); err != nil {
+			t.Fatalf("%s WriteDiff error: %#v", c.format, err)
+		}
+		if buf.String() != c.expected {
+			t.Errorf("%s actual:\n%s\nexpected:\n%s", c.format, buf.String(), c.expected)
+		}
+	}
+
+	if err := WriteDiff(FORMAT_CSV, "old.txt", "new.txt", d); err == nil {
+		t.Errorf("csv diff expected error")
+	}
+}
+
+func TestWriteNilAddresses(t *testing.T) {
+	orgOut := Out
+	defer func() { Out = orgOut }()
+
+	v6, _ := parser.Parse("2001:db8::/126")
+	host, _ := parser.Parse("192.168.1.1/32")
+
+	var buf bytes.Buffer
+	Out = &buf
+	WriteAll(NewWriter(false, false), []parser.CIDRInfo{v6, host})
+	if strings.Contains(buf.String(), "<nil>") || !strings.Contains(buf.String(), "broadcast   : -\n") {
+		t.Errorf("default actual:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	WriteAll(NewWriter(true, false), []parser.CIDRInfo{v6, host})
+	if strings.Contains(buf.String(), "<nil>") || !strings.Contains(buf.String(), "192.168.1.1/32,192.168.1.1,255.255.255.255,1,,,,,\n") {
+		t.Errorf("csv actual:\n%s", buf.String())
+	}
+}
+
+func TestWriteHostCount(t *testing.T) {
+	orgOut := Out
+	defer func() { Out = orgOut }()
+
+	v6, _ := parser.Parse("2001:db8::/64")
+	for _, c := range []struct {
+		isCsv    bool
+		expected string
+	}{
+		{false, "host_num    : 18446744073709551616\n"},
+		{true, ",ffff:ffff:ffff:ffff::,18446744073709551616,"},
+	} {
+		var buf bytes.Buffer
+		Out = &buf
+		WriteAll(NewWriter(c.isCsv, false), []parser.CIDRInfo{v6})
+		if !strings.Contains(buf.String(), c.expected) {
+			t.Errorf("actual:\n%s\nnot contains: %s", buf.String(), c.expected)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/goldeneggg/ipcl/lib/parser"
+	"github.com/goldeneggg/ipcl/lib/trie"
+	"github.com/goldeneggg/ipcl/lib/writer"
+)
+
+// runLookup annotates IP addresses from arguments or stdin with the longest
+// matching prefix of the CIDR list file and its label.
+func runLookup(oa *optArgs) error {
+	if oa.opts.File == "" {
+		return fmt.Errorf("CIDR list file is not assigned\n")
+	}
+
+	t, err := loadTrie(oa)
+	if err != nil {
+		return err
+	}
+
+	var results []writer.LookupResult
+	annotate := func(i int, src string) {
+		addr, err := netip.ParseAddr(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "IP string[%d] %s validate error: %s\n", i, src, err)
+			return
+		}
+
+		r := writer.LookupResult{IP: src}
+		if p, label, ok := t.Lookup(addr); ok {
+			r.Prefix, r.Label = p, label
+		}
+		results = append(results, r)
+	}
+
+	if len(oa.args) > 0 {
+		for i, a := range oa.args {
+			annotate(i, a)
+		}
+	} else {
+		scanner := bufio.NewScanner(os.Stdin)
+		for i := 0; scanner.Scan(); i++ {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				annotate(i, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	return writer.WriteLookup(outputFormat(oa), results)
+}
+
+// loadTrie reads the CIDR list file in the input format and returns a Trie
+// of the labels of the CIDRs.
+func loadTrie(oa *optArgs) (*trie.Trie[string], error) {
+	srcs, err := fromFile(oa)
+	if err != nil {
+		return nil, err
+	}
+
+	var cidrs []parser.CIDRInfo
+	for _, src := range srcs {
+		cidr, err := src.Parse()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", src.Line, err)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return trie.FromCIDRs(cidrs), nil
+}
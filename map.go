@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/goldeneggg/ipcl/lib/writer"
+)
+
+// runMap draws a grid of each parent network which shows the allocations of
+// -f in cells of --size.
+func runMap(oa *optArgs) error {
+	parents, used, err := parentsAndUsed(oa)
+	if err != nil {
+		return err
+	}
+
+	for i, p := range parents {
+		if i > 0 {
+			fmt.Fprintln(writer.Out)
+		}
+		bits := oa.opts.Size
+		if bits == 0 {
+			bits = writer.MapCellBits(p)
+		}
+		if err := writer.WriteMap(outputFormat(oa), p, used, bits, useColor(oa.opts.Color)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// useColor reports whether to color the output. "auto" colors only if
+// stdout is a terminal.
+func useColor(mode string) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/goldeneggg/ipcl/lib/parser"
+)
+
+// navigate replaces each CIDR with the networks selected by --next, --prev,
+// --parent, --supernet, --sibling and --children in this order. CIDRs are
+// returned as is if none of them is given.
+func navigate(cidrs []parser.CIDRInfo, opts *options) ([]parser.CIDRInfo, error) {
+	var navs []func(c parser.CIDRInfo) ([]parser.CIDRInfo, error)
+	if opts.Next {
+		navs = append(navs, one(parser.CIDRInfo.Next))
+	}
+	if opts.Prev {
+		navs = append(navs, one(parser.CIDRInfo.Prev))
+	}
+	if opts.Parent {
+		navs = append(navs, one(parser.CIDRInfo.Parent))
+	}
+	if opts.Supernet != nil {
+		bits := *opts.Supernet
+		navs = append(navs, one(func(c parser.CIDRInfo) (parser.CIDRInfo, error) {
+			return c.Supernet(bits)
+		}))
+	}
+	if opts.Sibling {
+		navs = append(navs, one(parser.CIDRInfo.Sibling))
+	}
+	if opts.Children {
+		navs = append(navs, parser.CIDRInfo.Children)
+	}
+	if len(navs) == 0 {
+		return cidrs, nil
+	}
+
+	var res []parser.CIDRInfo
+	for _, c := range cidrs {
+		for _, nav := range navs {
+			ns, err := nav(c)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, ns...)
+		}
+	}
+	return res, nil
+}
+
+func one(nav func(c parser.CIDRInfo) (parser.CIDRInfo, error)) func(c parser.CIDRInfo) ([]parser.CIDRInfo, error) {
+	return func(c parser.CIDRInfo) ([]parser.CIDRInfo, error) {
+		n, err := nav(c)
+		if err != nil {
+			return nil, err
+		}
+		return []parser.CIDRInfo{n}, nil
+	}
+}
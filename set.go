@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goldeneggg/ipcl/lib/ipset"
+)
+
+// runSet writes the minimal CIDRs of a set expression, or of the CIDRs of
+// -f without an expression.
+func runSet(oa *optArgs) error {
+	if len(oa.args) == 0 && oa.opts.File != "" {
+		cidrs, err := getCIDRs(oa)
+		if err != nil {
+			return err
+		}
+		return write(ipset.New(cidrs...).CIDRs(), oa)
+	}
+	if len(oa.args) == 0 {
+		return fmt.Errorf("Set expression is not assigned\n")
+	}
+
+	s, err := ipset.ParseExpr(strings.Join(oa.args, " "))
+	if err != nil {
+		return err
+	}
+
+	return write(s.CIDRs(), oa)
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/goldeneggg/ipcl/lib/cidrlist"
+	"github.com/goldeneggg/ipcl/lib/writer"
+)
+
+// runUsage writes the utilization of the parent networks by the CIDRs of
+// -f.
+func runUsage(oa *optArgs) error {
+	parents, used, err := parentsAndUsed(oa)
+	if err != nil {
+		return err
+	}
+
+	var usages []cidrlist.Usage
+	for _, p := range parents {
+		usages = append(usages, cidrlist.UsageOf(p, used))
+	}
+
+	return writer.WriteUsage(outputFormat(oa), usages)
+}